@@ -17,6 +17,7 @@ package bpf
 import (
 	"fmt"
 	"io"
+	"log"
 
 	"github.com/jecoz/lsaddr/onf"
 )
@@ -29,9 +30,16 @@ func NewEncoder(w io.Writer) *Encoder {
 	return &Encoder{w: w}
 }
 
+// Encode writes a BPF expression matching the traffic of the open network
+// files in "set". Unix domain sockets cannot be expressed with a BPF, and
+// are skipped with a warning.
 func (e *Encoder) Encode(set []onf.ONF) error {
 	var expr Expr
 	for _, v := range set {
+		if v.IsUnix() {
+			log.Printf("warning: skipping unix domain socket %v: not supported by bpf", v)
+			continue
+		}
 		src := string(FromAddr(NODIR, v.Src).Wrap())
 		dst := string(FromAddr(NODIR, v.Dst).Wrap())
 		expr = expr.Or(src).Or(dst)
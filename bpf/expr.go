@@ -66,9 +66,9 @@ func (e Expr) Wrap() Expr {
 
 // FromAddr returns a BPF from a network address, plus direction
 // information. Use NODIR to make a filter that matches both src and
-// dst packets.
+// dst packets. Missing addresses produce an empty expression.
 func FromAddr(d Dir, addr net.Addr) Expr {
-	if addr.String() == "" {
+	if addr == nil || addr.String() == "" {
 		return Expr("")
 	}
 
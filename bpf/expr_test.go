@@ -113,6 +113,9 @@ func TestFromAddr(t *testing.T) {
 			t.Fatalf("%d: expected \"%v\", found \"%v\"", i, v.bpf, expr)
 		}
 	}
+	if expr := bpf.FromAddr(bpf.NODIR, nil); expr != "" {
+		t.Fatalf("expected empty expression for nil address, found \"%v\"", expr)
+	}
 }
 
 func newAddr(s string) addr {
@@ -0,0 +1,166 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bundle resolves macOS application bundles, e.g.
+// "Visual Studio Code.app", into the executables they contain. Bundles
+// are plain directories, hence the package works on every platform.
+package bundle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"howett.net/plist"
+)
+
+// Ext is the extension of application bundles.
+const Ext = ".app"
+
+// SearchPaths lists the directories where bundles referenced by name
+// only are looked for, in order. "~" is expanded to the home directory.
+var SearchPaths = []string{
+	"/Applications",
+	"/Applications/Utilities",
+	"/System/Applications",
+	"/System/Applications/Utilities",
+	"~/Applications",
+}
+
+// Bundle is an application bundle, together with the helper bundles it
+// contains.
+type Bundle struct {
+	Path       string   // bundle directory
+	Name       string   // CFBundleName, if present
+	Identifier string   // CFBundleIdentifier, if present
+	Executable string   // absolute path of CFBundleExecutable
+	Helpers    []Bundle // bundles found under Contents/Frameworks
+}
+
+// info is the subset of Info.plist keys we are interested in.
+type info struct {
+	Name       string `plist:"CFBundleName"`
+	Identifier string `plist:"CFBundleIdentifier"`
+	Executable string `plist:"CFBundleExecutable"`
+}
+
+// IsBundle reports whether "name" refers to an application bundle, i.e.
+// it ends with ".app".
+func IsBundle(name string) bool {
+	return strings.HasSuffix(strings.ToLower(strings.TrimRight(name, "/")), Ext)
+}
+
+// Find returns the path of the bundle "name". Existing paths are returned
+// as they are, while bare names, e.g. "Safari.app", are looked for in
+// SearchPaths.
+func Find(name string) (string, error) {
+	name = strings.TrimRight(name, "/")
+	if _, err := os.Stat(name); err == nil {
+		return name, nil
+	}
+	if strings.ContainsRune(name, filepath.Separator) {
+		return "", fmt.Errorf("bundle %s not found", name)
+	}
+	home, _ := os.UserHomeDir()
+	for _, v := range SearchPaths {
+		if strings.HasPrefix(v, "~") {
+			if home == "" {
+				continue
+			}
+			v = filepath.Join(home, v[1:])
+		}
+		path := filepath.Join(v, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("bundle %s not found in %s", name, strings.Join(SearchPaths, ", "))
+}
+
+// Open reads the Info.plist of the bundle at "path", and walks its
+// Contents/Frameworks directory looking for helper bundles. Helpers whose
+// Info.plist cannot be read are skipped.
+func Open(path string) (*Bundle, error) {
+	b, err := readInfo(path)
+	if err != nil {
+		return nil, err
+	}
+	b.Helpers, err = findHelpers(filepath.Join(path, "Contents", "Frameworks"))
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func readInfo(path string) (*Bundle, error) {
+	f, err := os.Open(filepath.Join(path, "Contents", "Info.plist"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read bundle info: %w", err)
+	}
+	defer f.Close()
+
+	var i info
+	if err := plist.NewDecoder(f).Decode(&i); err != nil {
+		return nil, fmt.Errorf("unable to decode %s: %w", f.Name(), err)
+	}
+	if i.Executable == "" {
+		return nil, fmt.Errorf("%s: missing CFBundleExecutable", f.Name())
+	}
+	return &Bundle{
+		Path:       path,
+		Name:       i.Name,
+		Identifier: i.Identifier,
+		Executable: filepath.Join(path, "Contents", "MacOS", i.Executable),
+	}, nil
+}
+
+// findHelpers returns the bundles found anywhere under "dir", sorted by
+// path. Symbolic links are not followed, so that versioned frameworks,
+// e.g. "Versions/Current", are not visited twice.
+func findHelpers(dir string) ([]Bundle, error) {
+	helpers := []Bundle{}
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !fi.IsDir() || !IsBundle(path) {
+			return nil
+		}
+		if h, err := Open(path); err == nil {
+			helpers = append(helpers, *h)
+		}
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to walk %s: %w", dir, err)
+	}
+	sort.Slice(helpers, func(i, j int) bool { return helpers[i].Path < helpers[j].Path })
+	return helpers, nil
+}
+
+// Executables returns the executables of the bundle and of all its
+// helpers.
+func (b *Bundle) Executables() []string {
+	acc := []string{b.Executable}
+	for _, v := range b.Helpers {
+		acc = append(acc, v.Executables()...)
+	}
+	return acc
+}
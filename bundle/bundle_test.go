@@ -0,0 +1,113 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package bundle
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"howett.net/plist"
+)
+
+const vscode = "testdata/Visual Studio Code.app"
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+
+	b, err := Open(vscode)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, "Code", b.Name)
+	assert(t, "com.microsoft.VSCode", b.Identifier)
+	assert(t, filepath.Join(vscode, "Contents/MacOS/Electron"), b.Executable)
+	assert(t, 3, len(b.Helpers))
+
+	frameworks := filepath.Join(vscode, "Contents/Frameworks")
+	assert(t, []string{
+		filepath.Join(vscode, "Contents/MacOS/Electron"),
+		filepath.Join(frameworks, "Code Helper (Renderer).app/Contents/MacOS/Code Helper (Renderer)"),
+		filepath.Join(frameworks, "Code Helper.app/Contents/MacOS/Code Helper"),
+		filepath.Join(frameworks, "Electron Framework.framework/Versions/A/Helpers/Crash Reporter.app/Contents/MacOS/crashpad_handler"),
+	}, b.Executables())
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{"testdata/NoInfo.app", "testdata/Missing.app"} {
+		if _, err := Open(v); err == nil {
+			t.Fatalf("%s: expected error", v)
+		}
+	}
+}
+
+func TestOpen_Binary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "Spotify.app")
+	if err := os.MkdirAll(filepath.Join(path, "Contents"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := plist.Marshal(info{Name: "Spotify", Identifier: "com.spotify.client", Executable: "Spotify"}, plist.BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, "Contents", "Info.plist"), raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Open(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, "com.spotify.client", b.Identifier)
+	assert(t, []string{filepath.Join(path, "Contents/MacOS/Spotify")}, b.Executables())
+
+	old := SearchPaths
+	SearchPaths = []string{filepath.Join(dir, "missing"), dir}
+	defer func() { SearchPaths = old }()
+	found, err := Find("Spotify.app/")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, path, found)
+	if _, err := Find("Slack.app"); err == nil {
+		t.Fatalf("Expected error finding missing bundle")
+	}
+}
+
+func TestIsBundle(t *testing.T) {
+	t.Parallel()
+
+	assert(t, true, IsBundle("Visual Studio Code.app"))
+	assert(t, true, IsBundle("/Applications/Safari.APP/"))
+	assert(t, false, IsBundle("Spotify"))
+	assert(t, false, IsBundle(".*app.*"))
+}
+
+func assert(t *testing.T, exp, x interface{}) {
+	if !reflect.DeepEqual(exp, x) {
+		t.Fatalf("Assert failed: expected %v, found %v", exp, x)
+	}
+}
@@ -16,16 +16,25 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
+	"runtime"
 	"strings"
+	"time"
 
 	"github.com/jecoz/lsaddr/bpf"
+	"github.com/jecoz/lsaddr/bundle"
 	"github.com/jecoz/lsaddr/csv"
+	"github.com/jecoz/lsaddr/json"
 	"github.com/jecoz/lsaddr/onf"
+	"github.com/jecoz/lsaddr/table"
+	"github.com/jecoz/lsaddr/template"
+	"github.com/jecoz/lsaddr/tool"
 	"github.com/spf13/cobra"
 )
 
@@ -38,9 +47,31 @@ var (
 
 // Flags.
 var (
-	verbose bool
-	version bool
-	format  string
+	verbose      bool
+	version      bool
+	format       string
+	color        string
+	templateFile string
+	group        bool
+	columns      string
+	noHeader     bool
+	delimiter    string
+	quote        string
+	source       string
+	input        string
+	inputFormat  string
+	timeout      time.Duration
+	bin          string
+	toolArgs     []string
+	elevate      string
+	unix         bool
+	mode         string
+	dstCIDR      []string
+	excludeCIDR  []string
+	sport        []string
+	dport        []string
+	publicOnly   bool
+	tree         bool
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -67,20 +98,69 @@ var rootCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		set, err := onf.FetchAll()
+		offline := isOffline(source, input)
+		if tree && offline {
+			fmt.Fprintf(os.Stderr, "error: --tree requires the process table of the running system, it cannot be used with --input\n")
+			os.Exit(1)
+		}
+		fetcher, err := newFetcher(source, input, inputFormat)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		name, err := overriddenTool(source)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			os.Exit(1)
 		}
+		opts := onf.Options{
+			Options: tool.Options{
+				Timeout: timeout,
+				Tool:    name,
+				Path:    bin,
+				Args:    toolArgs,
+				Elevate: strings.Fields(elevate),
+			},
+			Unix: unix,
+		}
 		pivot := "*"
 		if len(args) > 0 {
 			pivot = args[0]
 		}
-		set, err = onf.Filter(set, pivot)
+		preds, err := newPredicates(&opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if !tree {
+			opts.States = stateHint(pivot, mode)
+		}
+		set, err := fetcher.Fetch(context.Background(), opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		var table onf.ProcessTable
+		if tree || isBundle(pivot, mode, offline) {
+			table, err = onf.FetchProcesses(context.Background(), opts)
+			if err != nil && tree {
+				fmt.Fprintf(os.Stderr, "error: unable to build process tree: %v\n", err)
+				os.Exit(1)
+			}
+			if err != nil {
+				log.Printf("unable to build process table, matching bundle executables by name: %v", err)
+			}
+		}
+		if tree {
+			set, err = filterTree(set, pivot, mode, table)
+		} else {
+			set, err = filter(set, pivot, mode, offline, table)
+		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error: unable to filter with %s: %v\n", pivot, err)
 			os.Exit(1)
 		}
+		set = onf.Select(set, preds...)
 
 		log.Printf("# of open network files: %d", len(set))
 		if err := enc.Encode(set); err != nil {
@@ -97,16 +177,302 @@ type Encoder interface {
 }
 
 func newEncoder(w io.Writer, format string) (Encoder, error) {
+	if templateFile != "" {
+		if format != "" && format != "template" {
+			return nil, fmt.Errorf("--template-file cannot be used with format %s", format)
+		}
+		text, err := ioutil.ReadFile(templateFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read template: %w", err)
+		}
+		return template.NewEncoder(w, string(text))
+	}
+	if strings.HasPrefix(format, "template=") {
+		return template.NewEncoder(w, strings.TrimPrefix(format, "template="))
+	}
+	tty := table.IsTerminal(os.Stdout)
+	if format == "" {
+		format = "csv"
+		if tty {
+			format = "table"
+		}
+	}
 	switch strings.ToLower(format) {
+	case "table":
+		opts := table.Options{Group: group}
+		if tty {
+			opts.Width = table.TerminalWidth(os.Stdout)
+		}
+		switch strings.ToLower(color) {
+		case "auto":
+			opts.Color = tty && os.Getenv("NO_COLOR") == ""
+		case "always":
+			opts.Color = true
+		case "never":
+		default:
+			return nil, fmt.Errorf("unrecognised color option %s", color)
+		}
+		return table.NewEncoder(w, opts), nil
 	case "csv":
-		return csv.NewEncoder(w), nil
+		opts, err := csvOptions(delimiter)
+		if err != nil {
+			return nil, err
+		}
+		return csv.NewEncoder(w, opts), nil
+	case "tsv":
+		opts, err := csvOptions("tab")
+		if err != nil {
+			return nil, err
+		}
+		return csv.NewEncoder(w, opts), nil
 	case "bpf":
 		return bpf.NewEncoder(w), nil
+	case "json":
+		return json.NewEncoder(w), nil
+	case "ndjson":
+		return json.NewLineEncoder(w), nil
 	default:
 		return nil, fmt.Errorf("unrecognised format option %s", format)
 	}
 }
 
+func csvOptions(delimiter string) (csv.Options, error) {
+	cols, err := csv.ParseColumns(columns)
+	if err != nil {
+		return csv.Options{}, err
+	}
+	comma, err := csv.ParseDelimiter(delimiter)
+	if err != nil {
+		return csv.Options{}, err
+	}
+	q, err := csv.ParseQuoteMode(quote)
+	if err != nil {
+		return csv.Options{}, err
+	}
+	return csv.Options{Columns: cols, NoHeader: noHeader, Comma: comma, Quote: q}, nil
+}
+
+// Filter modes.
+const (
+	modeRegex = "regex"
+	modeQuery = "query"
+)
+
+// filter selects the open network files matching "pivot", interpreted
+// according to "mode". In regex mode, pivots referring to an application
+// bundle select the processes running one of its executables, which are
+// identified using "table" when possible.
+func filter(set []onf.ONF, pivot, mode string, offline bool, table onf.ProcessTable) ([]onf.ONF, error) {
+	switch strings.ToLower(mode) {
+	case modeRegex:
+		if isBundle(pivot, mode, offline) {
+			return filterBundle(set, pivot, table)
+		}
+		return onf.Filter(set, pivot)
+	case modeQuery:
+		if pivot == "*" {
+			return set, nil
+		}
+		q, err := onf.ParseQuery(pivot)
+		if err != nil {
+			return set, err
+		}
+		return q.Filter(set), nil
+	default:
+		return set, fmt.Errorf("unrecognised mode option %s", mode)
+	}
+}
+
+// isBundle reports whether "pivot" should be resolved as an application
+// bundle. Bundles are looked up on macOS only, unless "pivot" is the path
+// of an existing one, e.g. copied from another machine. Bundles are never
+// resolved when "offline", as the local applications and processes are
+// unrelated to the captured output. Otherwise the pivot is matched as a
+// regular expression.
+func isBundle(pivot, mode string, offline bool) bool {
+	if strings.ToLower(mode) != modeRegex || !bundle.IsBundle(pivot) {
+		return false
+	}
+	if offline {
+		log.Printf("matching %s as a regex, bundles are not resolved with --input", pivot)
+		return false
+	}
+	if runtime.GOOS == "darwin" {
+		return true
+	}
+	_, err := os.Stat(pivot)
+	return err == nil
+}
+
+func filterBundle(set []onf.ONF, name string, table onf.ProcessTable) ([]onf.ONF, error) {
+	path, err := bundle.Find(name)
+	if err != nil {
+		return set, err
+	}
+	b, err := bundle.Open(path)
+	if err != nil {
+		return set, err
+	}
+	execs := b.Executables()
+	log.Printf("Bundle %s executables: %v", path, execs)
+	return onf.Select(set, onf.RunsExecutable(table, execs...)), nil
+}
+
+// filterTree filters "set" as filter does, but keeps the open network
+// files of the descendants of the matching processes too. Processes
+// without sockets are matched as well, as they may be the root of the
+// tree, e.g. an application that delegates networking to its helpers.
+// "table" belongs to the running system, hence it is never offline.
+func filterTree(set []onf.ONF, pivot, mode string, table onf.ProcessTable) ([]onf.ONF, error) {
+	matched, err := filter(append(table.Stubs(), set...), pivot, mode, false, table)
+	if err != nil {
+		return set, err
+	}
+	return table.Expand(set, matched), nil
+}
+
+// newPredicates builds the predicates selected by the address and port
+// flags. Port ranges are also stored in "opts" as hints for the backends,
+// when a single range is provided.
+func newPredicates(opts *onf.Options) ([]onf.Predicate, error) {
+	preds := []onf.Predicate{}
+	if len(dstCIDR) > 0 {
+		nets, err := parseCIDRs(dstCIDR)
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, onf.DstInCIDR(nets...))
+	}
+	if len(excludeCIDR) > 0 {
+		nets, err := parseCIDRs(excludeCIDR)
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, onf.Not(onf.DstInCIDR(nets...)))
+	}
+	if len(sport) > 0 {
+		ranges, err := parsePortRanges(sport)
+		if err != nil {
+			return nil, err
+		}
+		if len(ranges) == 1 {
+			opts.Sport = &ranges[0]
+		}
+		preds = append(preds, onf.SrcPortIn(ranges...))
+	}
+	if len(dport) > 0 {
+		ranges, err := parsePortRanges(dport)
+		if err != nil {
+			return nil, err
+		}
+		if len(ranges) == 1 {
+			opts.Dport = &ranges[0]
+		}
+		preds = append(preds, onf.DstPortIn(ranges...))
+	}
+	if publicOnly {
+		preds = append(preds, onf.DstClass(onf.ClassPublic))
+	}
+	return preds, nil
+}
+
+// stateHint returns the states required by "pivot" when it is a query,
+// which backends may use to skip the other sockets. It must not be used
+// with --tree, which keeps the sockets of the descendants whatever their
+// state. Invalid queries are reported by filter.
+func stateHint(pivot, mode string) []onf.State {
+	if strings.ToLower(mode) != modeQuery || pivot == "*" {
+		return nil
+	}
+	q, err := onf.ParseQuery(pivot)
+	if err != nil {
+		return nil
+	}
+	return q.States()
+}
+
+func parseCIDRs(ss []string) ([]*net.IPNet, error) {
+	acc := make([]*net.IPNet, 0, len(ss))
+	for _, v := range ss {
+		ipnet, err := onf.ParseCIDR(v)
+		if err != nil {
+			return nil, err
+		}
+		acc = append(acc, ipnet)
+	}
+	return acc, nil
+}
+
+func parsePortRanges(ss []string) ([]onf.PortRange, error) {
+	acc := make([]onf.PortRange, 0, len(ss))
+	for _, v := range ss {
+		r, err := onf.ParsePortRange(v)
+		if err != nil {
+			return nil, err
+		}
+		acc = append(acc, r)
+	}
+	return acc, nil
+}
+
+// sourceTools maps the sources that run a single external tool to its
+// name.
+var sourceTools = map[string]string{
+	"lsof":          "lsof",
+	"netstat":       "netstat",
+	"netstat-b":     "netstat",
+	"netstat-macos": "netstat",
+	"powershell":    "powershell",
+	"ss":            "ss",
+	"sockstat":      "sockstat",
+}
+
+// overriddenTool returns the name of the tool "--bin" and "--tool-arg"
+// apply to, which must be named by "source". Otherwise they could end up
+// being used by a fallback, e.g. lsof after ss failed.
+func overriddenTool(source string) (string, error) {
+	if bin == "" && len(toolArgs) == 0 {
+		return "", nil
+	}
+	name, ok := sourceTools[strings.ToLower(source)]
+	if !ok {
+		return "", fmt.Errorf("--bin and --tool-arg require a --source running a single tool, e.g. --source lsof")
+	}
+	return name, nil
+}
+
+// isOffline reports whether open network files are parsed from a
+// captured output rather than collected from the running system.
+func isOffline(source, input string) bool {
+	return input != "" || strings.ToLower(source) == "file"
+}
+
+func newFetcher(source, input, inputFormat string) (onf.Fetcher, error) {
+	if !isOffline(source, input) {
+		return onf.Lookup(source)
+	}
+	f := onf.File{Path: input, Format: inputFormat}
+	switch strings.ToLower(inputFormat) {
+	case "json", "ndjson":
+		f.Decode = func(r io.Reader) ([]onf.ONF, error) {
+			return json.NewDecoder(r).Decode()
+		}
+	case "csv", "tsv":
+		d := delimiter
+		if strings.ToLower(inputFormat) == "tsv" {
+			d = "tab"
+		}
+		opts, err := csvOptions(d)
+		if err != nil {
+			return nil, err
+		}
+		f.Decode = func(r io.Reader) ([]onf.ONF, error) {
+			return csv.NewDecoder(r, opts).Decode()
+		}
+	}
+	return f, nil
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -119,14 +485,131 @@ func Execute() {
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Increment logger verbosity.")
 	rootCmd.PersistentFlags().BoolVarP(&version, "version", "", false, "Print build information such as version, commit and build time.")
-	rootCmd.PersistentFlags().StringVarP(&format, "format", "f", "csv", "Choose output format.")
+	rootCmd.PersistentFlags().StringVarP(&format, "format", "f", "", "Choose output format, \"table\" when the output is a terminal and \"csv\" otherwise.")
+	rootCmd.PersistentFlags().StringVarP(&columns, "columns", "", csv.DefaultColumns, "Columns of the csv and tsv output, separated by commas.")
+	rootCmd.PersistentFlags().BoolVarP(&noHeader, "no-header", "", false, "Omit the header line of the csv and tsv output.")
+	rootCmd.PersistentFlags().StringVarP(&delimiter, "delimiter", "", ",", "Field delimiter of the csv output, a single character or \"tab\".")
+	rootCmd.PersistentFlags().StringVarP(&quote, "quote", "", "minimal", "Fields of the csv and tsv output enclosed in quotes: \"minimal\", \"all\" or \"none\".")
+	rootCmd.PersistentFlags().StringVarP(&color, "color", "", "auto", "Colour the table output: \"auto\", \"always\" or \"never\".")
+	rootCmd.PersistentFlags().StringVarP(&templateFile, "template-file", "", "", "Encode the output using the Go template contained in the file provided.")
+	rootCmd.PersistentFlags().BoolVarP(&group, "group", "g", false, "Group the table output by process.")
+	rootCmd.PersistentFlags().StringVarP(&source, "source", "s", onf.DefaultSource, "Choose where open network files are collected from.")
+	rootCmd.PersistentFlags().StringVarP(&input, "input", "i", "", "Read open network files from a captured output instead of the running system (\"-\" for stdin).")
+	rootCmd.PersistentFlags().StringVarP(&inputFormat, "input-format", "", onf.FormatLsof, "Format of the captured output read with --input.")
+	rootCmd.PersistentFlags().DurationVarP(&timeout, "timeout", "t", tool.DefaultTimeout, "Maximum execution time of external tools (0 to disable).")
+	rootCmd.PersistentFlags().StringVarP(&bin, "bin", "", "", "Path of the external tool to execute, overriding the one found in $PATH.")
+	rootCmd.PersistentFlags().StringArrayVarP(&toolArgs, "tool-arg", "", []string{}, "Additional argument passed to the external tool (repeatable).")
+	rootCmd.PersistentFlags().StringVarP(&elevate, "elevate", "", "", "Command prefix used to run external tools with elevated privileges, \"sudo\" if no value is given.")
+	rootCmd.PersistentFlags().Lookup("elevate").NoOptDefVal = "sudo"
+	rootCmd.PersistentFlags().BoolVarP(&unix, "unix", "u", false, "Include unix domain sockets.")
+	rootCmd.PersistentFlags().StringSliceVarP(&dstCIDR, "dst-cidr", "", []string{}, "Keep only connections whose destination is in one of the CIDRs provided.")
+	rootCmd.PersistentFlags().StringSliceVarP(&excludeCIDR, "exclude-cidr", "", []string{}, "Discard connections whose destination is in one of the CIDRs provided.")
+	rootCmd.PersistentFlags().StringSliceVarP(&sport, "sport", "", []string{}, "Keep only connections whose source port is in one of the ports or ranges provided, e.g. 30000-32767.")
+	rootCmd.PersistentFlags().StringSliceVarP(&dport, "dport", "", []string{}, "Keep only connections whose destination port is in one of the ports or ranges provided, e.g. 80,443.")
+	rootCmd.PersistentFlags().BoolVarP(&publicOnly, "public-only", "", false, "Keep only connections to public addresses.")
+	rootCmd.PersistentFlags().BoolVarP(&tree, "tree", "", false, "Include the connections of the descendants of the matching processes.")
+	rootCmd.PersistentFlags().StringVarP(&mode, "mode", "m", modeRegex, "Choose how the filter argument is interpreted: \"regex\" or \"query\".")
 }
 
 const usage = `List open network connections. Results can be filtered passing a raw regular expression as argument (check out https://golang.org/pkg/regexp/ to learn how to properly format your regex).
 
+Using "--mode=query" or "-m query", the argument is a filter expression over the fields of the
+open network files instead, e.g.
+	cmd ~ "Spotify" and dport in (80, 443) and state = established and not dst in 10.0.0.0/8
+Comparisons support the "=", "!=", "~" and "!~" (regex), "<", "<=", ">", ">=" and "in" operators,
+and are combined with "and", "or", "not" and parentheses. Available fields are cmd, pid, fd, user,
+uid, family, proto, state, src, dst, sport, dport, sclass, dclass, inode, peerpid and raw. Numeric
+fields match port ranges too, e.g. "sport in 30000-32767". Addresses match CIDRs, ips or their
+complete representation, while their classes are one of loopback, link-local, private,
+multicast, reserved, unspecified or public.
+
+When the argument names a macOS application bundle, e.g. "Visual Studio Code.app", the
+connections of the processes running its executable, or the executable of any helper bundle
+found under "Contents/Frameworks", are selected. Bundles referred to by name are looked for in
+"/Applications", "/System/Applications" and "~/Applications". On other systems the argument is
+resolved only when it is the path of an existing bundle, and matched as a regex otherwise.
+Bundles are never resolved with "--input", as they belong to the running system.
+
+With "--tree", the connections of every descendant of the matching processes are kept too, which
+is useful for applications that delegate networking to helper processes with different names.
+The process tree is read from /proc on linux, and from "ps" on the other unix systems. As it
+belongs to the running system, "--tree" cannot be combined with "--input".
+
+Results can also be narrowed down by address and port: "--dst-cidr" and "--exclude-cidr" keep or
+discard the connections whose destination is in the CIDRs provided, "--sport" and "--dport" keep
+the connections using the ports or port ranges provided, e.g. "--dport 80,443" or
+"--sport 30000-32767", while "--public-only" discards the connections to loopback, link-local,
+private (RFC 1918 and ULA), multicast and reserved addresses.
+
 Using the "--format" or "-f" flag, it is possible to decide the format/encoding of the output produced. Possible values are:
+- "table": produces a table aligned to the width of the terminal, which is the default when the
+output is a terminal. States and addresses are coloured, unless "--color never" is used or the
+NO_COLOR environment variable is set, and long command names and addresses are truncated.
+"--group" or "-g" groups the open network files by process.
 - "bpf": produces a Berkley Packet Filter expression, which, if given to a tool that supports
 bpfs, will make it capture only the packets headed to/coming from the destination addresses
-of the open network files collected.
-- "csv": produces a CSV encoded table of the open network files collected.
+of the open network files collected. Unix domain sockets are skipped.
+- "csv": produces a CSV encoded table of the open network files collected. "--columns" selects
+its columns, among pid, cmd, net, src, dst, sport, dport, sclass, dclass, fd, user, uid, family,
+proto, state, inode, peerpid, created and raw, e.g. "--columns pid,cmd,state,sport,dst,dport,user"
+(default "pid,cmd,net,src,dst"), or "all" of them. "--no-header" omits the header line, "--delimiter" changes the
+field delimiter, e.g. "--delimiter ';'", and "--quote" decides which fields are enclosed in
+quotes: the ones that need it ("minimal", default), "all" or "none".
+- "tsv": same as "csv", with fields separated by tabs.
+- "json": produces a JSON document containing the open network files collected, together with
+the schema version, the host and the time they were collected at. Addresses are objects with
+"ip", "port" and "network" fields, or "network" and "path" for unix domain sockets, and null
+when missing. The port is omitted for sockets not bound to any, e.g. "*:*".
+- "ndjson": produces one JSON object for each open network file collected, one per line.
+- "template=<text>": executes the Go template provided for each open network file collected,
+e.g. 'template={{.Pid}} {{.Cmd}} {{.Dst.IP}}', see https://golang.org/pkg/text/template/.
+"--template-file" reads the template from a file instead. Templates can use the fields Raw, Cmd,
+Pid, Fd, User, Uid, Family, Proto, State, Inode, Src, Dst, PeerPid and CreatedAt, where Src and
+Dst have the fields Network, IP, Zone, Port and Path (unix domain sockets only), and the
+functions "host" and "port" (of an address), "family" (of an address: ipv4, ipv6 or unix),
+"age" (time elapsed since a time, e.g. "5m") and "join" (a separator followed by the values
+to join).
+
+Using the "--source" or "-s" flag, it is possible to decide where the open network files are
+collected from. Possible values are:
+- "auto": picks the best source available on the current platform (default).
+- "lsof": runs "lsof -i -n -P" using its machine readable field output.
+- "powershell": runs Get-NetTCPConnection and Get-NetUDPEndpoint (windows), naming processes with
+"tasklist".
+- "netstat": runs "netstat -nao" (windows), naming processes with "tasklist".
+- "netstat-b": runs "netstat -nabo" (windows), which reports the executable and the service owning
+each connection. It requires elevated privileges.
+- "netstat-macos": runs "netstat -anvW" (macos), which reports the pid owning each socket without
+requiring elevated privileges. Unix domain sockets are not collected.
+- "procfs": reads the socket tables under /proc/net (linux).
+- "netlink": queries the kernel using sock_diag, as "ss" does (linux).
+- "ss": runs "ss -tuanpieO" (linux). Unix domain sockets are not collected.
+- "sockstat": runs "sockstat -46 -c -l" (freebsd and other BSDs). Unix domain sockets are not
+collected.
+- "file": parses a previously captured output, see below.
+
+Using the "--input" or "-i" flag, the open network files are parsed from a file ("-" for stdin)
+containing the output of a tool captured elsewhere, which then goes through the same filters and
+encoders. Use "--input-format" to describe its content. Possible values are:
+- "lsof": output of "lsof -i -n -P" (default).
+- "netstat": output of "netstat -nao".
+- "netstat-b": output of "netstat -nab" or "netstat -nabo".
+- "netstat-macos": output of macOS' "netstat -anv", e.g. "netstat -anv -p tcp".
+- "powershell": output of "Get-NetTCPConnection | ConvertTo-Json" or "Get-NetUDPEndpoint | ConvertTo-Json".
+- "ss": output of "ss -tuanpieO" or "ss -tuanpie".
+- "sockstat": output of "sockstat -46", optionally with the -s, -c and -l options.
+- "proc": content of /proc/net/tcp, /proc/net/udp and their IPv6 variants.
+- "json" and "ndjson": a snapshot produced by lsaddr with "-f json" or "-f ndjson".
+- "csv" and "tsv": a snapshot produced by lsaddr with "-f csv" or "-f tsv". The columns are read
+from the header line, or from "--columns" with "--no-header". Use "--columns all" to save every
+field of the open network files.
+
+Unix domain sockets are included with the "--unix" or "-u" flag. Their source address is the
+path they are bound to, while their destination is the path of their peer, if any.
+
+External tools, such as "lsof" and "netstat", are killed after "--timeout", which bounds the
+sock_diag queries of "netlink" too. Use "--bin" to execute a different binary and "--tool-arg"
+to pass additional arguments to it, both requiring a "--source" which runs a single tool, e.g.
+"--source lsof". Use "--elevate" to run it with elevated privileges, e.g. "--elevate" for "sudo"
+or "--elevate='doas'".
 `
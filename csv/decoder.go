@@ -0,0 +1,158 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package csv
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/onf"
+)
+
+// Decoder reads back the open network files written by Encoder.
+type Decoder struct {
+	r    io.Reader
+	opts Options
+}
+
+// NewDecoder returns a Decoder reading the columns listed in the header
+// line, or the ones configured in "opts" when NoHeader is set. Only the
+// delimiter and the quoting mode are used otherwise.
+func NewDecoder(r io.Reader, opts Options) *Decoder {
+	if opts.Comma == 0 {
+		opts.Comma = ','
+	}
+	return &Decoder{r: r, opts: opts}
+}
+
+// Decode reads every record of the decoder's reader. Fields are
+// reconstructed from the columns available, while columns derived from
+// the addresses, such as "sport", are ignored. Addresses belong to the
+// network of the "net" column, or to the protocol when it is not
+// available. Empty cells are decoded as missing addresses, i.e. empty
+// ones, as the backends do. Use AllColumns for a lossless output.
+func (d *Decoder) Decode() ([]onf.ONF, error) {
+	r := csv.NewReader(d.r)
+	r.Comma = d.opts.Comma
+	r.LazyQuotes = d.opts.Quote == QuoteNone
+
+	cols := d.opts.Columns
+	line := 0
+	if !d.opts.NoHeader {
+		header, err := r.Read()
+		if err == io.EOF {
+			return []onf.ONF{}, nil
+		}
+		if err != nil {
+			return []onf.ONF{}, fmt.Errorf("unable to read header: %w", err)
+		}
+		line++
+		if cols, err = ParseColumns(strings.Join(header, ",")); err != nil {
+			return []onf.ONF{}, fmt.Errorf("invalid header: %w", err)
+		}
+	}
+	if len(cols) == 0 {
+		return []onf.ONF{}, fmt.Errorf("no columns to decode")
+	}
+	r.FieldsPerRecord = len(cols)
+
+	set := []onf.ONF{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return set, nil
+		}
+		if err != nil {
+			return set, err
+		}
+		line++
+		f, err := decodeRecord(cols, record)
+		if err != nil {
+			return set, fmt.Errorf("record %d: %w", line, err)
+		}
+		set = append(set, f)
+	}
+}
+
+func decodeRecord(cols []Column, record []string) (onf.ONF, error) {
+	cells := make(map[string]string, len(cols))
+	for i, c := range cols {
+		cells[c.Name] = record[i]
+	}
+	f := onf.ONF{
+		Cmd:    cells["cmd"],
+		User:   cells["user"],
+		Family: onf.Family(cells["family"]),
+		Proto:  cells["proto"],
+		State:  onf.ParseState(cells["state"]),
+		Raw:    cells["raw"],
+	}
+
+	ints := []struct {
+		name string
+		dst  *int
+		def  int
+	}{
+		{"pid", &f.Pid, 0},
+		{"fd", &f.Fd, -1},
+		{"uid", &f.Uid, -1},
+		{"peerpid", &f.PeerPid, 0},
+	}
+	for _, v := range ints {
+		s, ok := cells[v.name]
+		if !ok {
+			*v.dst = v.def
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return f, fmt.Errorf("invalid %s %q: %w", v.name, s, err)
+		}
+		*v.dst = n
+	}
+	if s, ok := cells["inode"]; ok {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return f, fmt.Errorf("invalid inode %q: %w", s, err)
+		}
+		f.Inode = n
+	}
+	if s := cells["created"]; s != "" {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return f, fmt.Errorf("invalid created %q: %w", s, err)
+		}
+		f.CreatedAt = t
+	}
+
+	network, ok := cells["net"]
+	if !ok {
+		network = f.Proto
+	}
+	f.Src = decodeAddr(network, cells["src"])
+	f.Dst = decodeAddr(network, cells["dst"])
+	return f, nil
+}
+
+func decodeAddr(network, s string) net.Addr {
+	return internal.NewAddr(network, s)
+}
@@ -0,0 +1,120 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package csv_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jecoz/lsaddr/csv"
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/onf"
+)
+
+// netFiles1 contains every field the encoder is able to write, so that
+// it survives a round trip when all columns are selected.
+var netFiles1 = []onf.ONF{
+	{
+		Raw:       "foo 101 \"udp\",\nbar",
+		Cmd:       "foo, bar",
+		Pid:       101,
+		Fd:        3,
+		User:      "dani",
+		Uid:       501,
+		Family:    onf.FamilyIPv4,
+		Proto:     "udp",
+		State:     onf.StateEstablished,
+		Inode:     21445,
+		Src:       internal.NewAddr("udp", "192.168.0.61:54104"),
+		Dst:       internal.NewAddr("udp", "52.94.218.7:443"),
+		CreatedAt: time.Date(2019, 9, 1, 12, 0, 0, 500, time.UTC),
+	},
+	// Listening sockets have no peer.
+	{Pid: 102, Fd: -1, Uid: -1, Family: onf.FamilyIPv6, Proto: "tcp", State: onf.StateListen, Src: internal.NewAddr("tcp", "[::1]:60051"), Dst: internal.NewAddr("tcp", "")},
+	{Pid: 103, Fd: 7, Uid: 0, User: "root", Family: onf.FamilyUnix, Proto: "unix", Src: onf.NewUnixAddr("/run/foo.sock"), Dst: onf.NewUnixAddr(""), PeerPid: 104},
+	// Some backends do not report the protocol.
+	{Pid: 105, Fd: -1, Uid: -1, Src: internal.NewAddr("udp", "10.0.0.1:53"), Dst: internal.NewAddr("udp", "")},
+}
+
+func TestDecode_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tt := []csv.Options{
+		{},
+		{Comma: '\t'},
+		{Comma: ';', Quote: csv.QuoteAll},
+		{NoHeader: true},
+	}
+	for i, v := range tt {
+		cols, err := csv.ParseColumns(csv.AllColumns)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		v.Columns = cols
+		var w strings.Builder
+		if err := csv.NewEncoder(&w, v).Encode(netFiles1); err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		set, err := csv.NewDecoder(strings.NewReader(w.String()), v).Decode()
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %v\n%s", i, err, w.String())
+		}
+		if !reflect.DeepEqual(netFiles1, set) {
+			t.Fatalf("%d: unexpected round trip: wanted\n%v,\nfound\n%v", i, netFiles1, set)
+		}
+	}
+}
+
+func TestDecode_Columns(t *testing.T) {
+	t.Parallel()
+
+	// The network and the default columns are used to fill what is
+	// missing, while derived columns are ignored.
+	in := `PID,Cmd,PROTO,SRC,DST,DPORT
+101,foo,udp,192.168.0.61:54104,52.94.218.7:443,443
+0,,tcp,,,
+`
+	set, err := csv.NewDecoder(strings.NewReader(in), csv.Options{}).Decode()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	exp := []onf.ONF{
+		{Pid: 101, Cmd: "foo", Fd: -1, Uid: -1, Proto: "udp", Src: internal.NewAddr("udp", "192.168.0.61:54104"), Dst: internal.NewAddr("udp", "52.94.218.7:443")},
+		{Fd: -1, Uid: -1, Proto: "tcp", Src: internal.NewAddr("tcp", ""), Dst: internal.NewAddr("tcp", "")},
+	}
+	if !reflect.DeepEqual(exp, set) {
+		t.Fatalf("Unexpected set: wanted\n%v,\nfound\n%v", exp, set)
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tt := []string{
+		"PID,FOO\n1,2\n",
+		"PID,CMD\nfoo,bar\n",
+		"PID,CMD\n1\n",
+		"PID,CREATED\n1,yesterday\n",
+		"INODE\n-1\n",
+	}
+	for i, v := range tt {
+		if _, err := csv.NewDecoder(strings.NewReader(v), csv.Options{}).Decode(); err == nil {
+			t.Fatalf("%d: expected error decoding %q", i, v)
+		}
+	}
+}
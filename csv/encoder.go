@@ -15,46 +15,251 @@
 package csv
 
 import (
-	"encoding/csv"
+	"bufio"
+	"fmt"
 	"io"
+	"net"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/jecoz/lsaddr/onf"
 )
 
-// Encoder returns an Encoder which encodes a list
-// of NetFile into CSV format.
+// Column is a column of the CSV output.
+type Column struct {
+	Name  string // name of the column, printed uppercase in the header
+	Value func(onf.ONF) string
+}
+
+// Columns lists the columns available, one for each field of the open
+// network files, plus the ones derived from their addresses.
+var Columns = []Column{
+	{"pid", func(f onf.ONF) string { return strconv.Itoa(f.Pid) }},
+	{"cmd", func(f onf.ONF) string { return f.Cmd }},
+	{"net", network},
+	{"src", func(f onf.ONF) string { return addr(f.Src) }},
+	{"dst", func(f onf.ONF) string { return addr(f.Dst) }},
+	{"sport", func(f onf.ONF) string { return port(f.Src) }},
+	{"dport", func(f onf.ONF) string { return port(f.Dst) }},
+	{"sclass", func(f onf.ONF) string { return class(f.Src) }},
+	{"dclass", func(f onf.ONF) string { return class(f.Dst) }},
+	{"fd", func(f onf.ONF) string { return strconv.Itoa(f.Fd) }},
+	{"user", func(f onf.ONF) string { return f.User }},
+	{"uid", func(f onf.ONF) string { return strconv.Itoa(f.Uid) }},
+	{"family", func(f onf.ONF) string { return string(f.Family) }},
+	{"proto", func(f onf.ONF) string { return f.Proto }},
+	{"state", func(f onf.ONF) string { return f.State.String() }},
+	{"inode", func(f onf.ONF) string { return strconv.FormatUint(f.Inode, 10) }},
+	{"peerpid", func(f onf.ONF) string { return strconv.Itoa(f.PeerPid) }},
+	{"created", func(f onf.ONF) string { return created(f.CreatedAt) }},
+	{"raw", func(f onf.ONF) string { return f.Raw }},
+}
+
+// DefaultColumns are the columns encoded when none are configured.
+const DefaultColumns = "pid,cmd,net,src,dst"
+
+// AllColumns selects every column, so that Decoder is able to restore
+// every field.
+const AllColumns = "all"
+
+// ParseColumns returns the columns named in "s", separated by commas,
+// e.g. "pid,cmd,state,sport,dst,dport,user", or every column if "s" is
+// AllColumns.
+func ParseColumns(s string) ([]Column, error) {
+	if strings.ToLower(strings.TrimSpace(s)) == AllColumns {
+		return append([]Column{}, Columns...), nil
+	}
+	cols := []Column{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		c, ok := lookupColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q, available columns are %s", name, columnNames())
+		}
+		cols = append(cols, c)
+	}
+	return cols, nil
+}
+
+func lookupColumn(name string) (Column, bool) {
+	for _, v := range Columns {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return Column{}, false
+}
+
+func columnNames() string {
+	names := make([]string, len(Columns))
+	for i, v := range Columns {
+		names[i] = v.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// QuoteMode decides which fields are enclosed in quotes.
+type QuoteMode int
+
+// Supported quote modes.
+const (
+	QuoteMinimal QuoteMode = iota // fields containing delimiters, quotes or new lines
+	QuoteAll                      // every field
+	QuoteNone                     // no field, even if the output becomes ambiguous
+)
+
+// ParseQuoteMode returns the quote mode named "s": "minimal", "all" or
+// "none".
+func ParseQuoteMode(s string) (QuoteMode, error) {
+	switch strings.ToLower(s) {
+	case "", "minimal":
+		return QuoteMinimal, nil
+	case "all":
+		return QuoteAll, nil
+	case "none":
+		return QuoteNone, nil
+	default:
+		return QuoteMinimal, fmt.Errorf("unknown quote mode %q", s)
+	}
+}
+
+// ParseDelimiter returns the delimiter described by "s", which is either
+// a single character, or "tab" and "\t" for tab separated values.
+func ParseDelimiter(s string) (rune, error) {
+	switch s {
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	r, n := utf8.DecodeRuneInString(s)
+	if n == 0 || n != len(s) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("invalid delimiter %q", s)
+	}
+	return r, nil
+}
+
+// Options configure the Encoder.
+type Options struct {
+	Columns  []Column  // columns encoded, DefaultColumns when empty
+	NoHeader bool      // omit the header line
+	Comma    rune      // field delimiter, ',' when zero
+	Quote    QuoteMode // fields enclosed in quotes
+}
+
+// Encoder encodes a list of open network files into CSV format, using
+// the columns, delimiter and quoting configured.
 type Encoder struct {
-	w *csv.Writer
+	w    io.Writer
+	opts Options
 }
 
-func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{
-		w: csv.NewWriter(w),
+func NewEncoder(w io.Writer, opts Options) *Encoder {
+	if len(opts.Columns) == 0 {
+		opts.Columns, _ = ParseColumns(DefaultColumns)
+	}
+	if opts.Comma == 0 {
+		opts.Comma = ','
 	}
+	return &Encoder{w: w, opts: opts}
 }
 
 // Encode writes `l` into encoder's writer in CSV format. Some data may have been
 // written to the writer even upon error.
 func (e *Encoder) Encode(l []onf.ONF) error {
-	header := []string{"PID", "CMD", "NET", "SRC", "DST"}
-	if err := e.w.Write(header); err != nil {
-		return err
+	w := bufio.NewWriter(e.w)
+	if !e.opts.NoHeader {
+		header := make([]string, len(e.opts.Columns))
+		for i, c := range e.opts.Columns {
+			header[i] = strings.ToUpper(c.Name)
+		}
+		e.write(w, header)
 	}
 
+	record := make([]string, len(e.opts.Columns))
 	for _, v := range l {
-		record := []string{
-			strconv.Itoa(v.Pid),
-			v.Cmd,
-			v.Src.Network(),
-			v.Src.String(),
-			v.Dst.String(),
+		for i, c := range e.opts.Columns {
+			record[i] = c.Value(v)
+		}
+		e.write(w, record)
+	}
+	return w.Flush()
+}
+
+func (e *Encoder) write(w *bufio.Writer, record []string) {
+	for i, v := range record {
+		if i > 0 {
+			w.WriteRune(e.opts.Comma)
 		}
-		if err := e.w.Write(record); err != nil {
-			return err
+		if !e.needsQuotes(v) {
+			w.WriteString(v)
+			continue
 		}
+		w.WriteByte('"')
+		w.WriteString(strings.Replace(v, `"`, `""`, -1))
+		w.WriteByte('"')
+	}
+	w.WriteByte('\n')
+}
+
+// needsQuotes follows the rules of encoding/csv in minimal mode.
+func (e *Encoder) needsQuotes(v string) bool {
+	switch e.opts.Quote {
+	case QuoteAll:
+		return true
+	case QuoteNone:
+		return false
+	}
+	if v == "" {
+		return false
+	}
+	if strings.ContainsRune(v, e.opts.Comma) || strings.ContainsAny(v, "\"\r\n") {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(v)
+	return r == ' ' || r == '\t'
+}
+
+// network returns the network of the addresses of "f", or its protocol
+// when they are missing.
+func network(f onf.ONF) string {
+	if f.Src != nil {
+		return f.Src.Network()
+	}
+	if f.Dst != nil {
+		return f.Dst.Network()
+	}
+	return f.Proto
+}
+
+func addr(a net.Addr) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
+
+func port(a net.Addr) string {
+	if a == nil || a.Network() == "unix" {
+		return ""
+	}
+	_, port, err := net.SplitHostPort(a.String())
+	if err != nil {
+		return ""
 	}
+	return port
+}
 
-	e.w.Flush()
-	return e.w.Error()
+func class(a net.Addr) string {
+	if a == nil || a.String() == "" {
+		return ""
+	}
+	return onf.ClassOfAddr(a).String()
+}
+
+func created(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
 }
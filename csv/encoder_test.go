@@ -16,40 +16,160 @@
 package csv_test
 
 import (
-	"net"
 	"strings"
 	"testing"
+	"time"
 
-	"github.com/booster-proj/lsaddr/csv"
-	"github.com/booster-proj/lsaddr/lookup"
+	"github.com/jecoz/lsaddr/csv"
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/onf"
 )
 
 func TestEncode_CSV(t *testing.T) {
 	t.Parallel()
 	l := netFiles0
 	var w strings.Builder
-	if err := csv.NewEncoder(&w).Encode(l); err != nil {
+	if err := csv.NewEncoder(&w, csv.Options{}).Encode(l); err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
 
 	expOut := `PID,CMD,NET,SRC,DST
 101,foo,udp,192.168.0.61:54104,52.94.218.7:443
 102,,udp,[::1]:60051,[::1]:60052
+0,,tcp,,
 `
 	if expOut != w.String() {
 		t.Fatalf("Unexpected output: wanted\n\"%s\",\nfound\n\"%s\"", expOut, w.String())
 	}
 }
 
-var netFiles0 = []lookup.NetFile{
-	{"foo", 101, newUDPAddr("192.168.0.61:54104"), newUDPAddr("52.94.218.7:443")},
-	{"", 102, newUDPAddr("[::1]:60051"), newUDPAddr("[::1]:60052")},
+func TestEncode_Options(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		columns string
+		opts    csv.Options
+		out     string
+	}{
+		{
+			columns: "pid,cmd,state,sport,dst,dport,user",
+			out: `PID,CMD,STATE,SPORT,DST,DPORT,USER
+101,foo,ESTABLISHED,54104,52.94.218.7:443,443,dani
+102,,UNKNOWN,60051,[::1]:60052,60052,
+0,,UNKNOWN,,,,
+`,
+		},
+		{
+			columns: "pid,sclass,dclass,fd,uid,family,proto,inode,peerpid,created",
+			opts:    csv.Options{NoHeader: true},
+			out: `101,private,public,3,501,ipv4,udp,0,0,2019-09-01T12:00:00Z
+102,loopback,loopback,-1,-1,ipv6,udp,0,0,
+0,,,-1,-1,,tcp,0,0,
+`,
+		},
+		{
+			columns: "pid,cmd,raw",
+			opts:    csv.Options{Comma: '\t'},
+			out: "PID\tCMD\tRAW\n" +
+				"101\tfoo\t\"foo 101 \"\"udp\"\"\"\n" +
+				"102\t\t\n" +
+				"0\t\t\n",
+		},
+		{
+			columns: "pid,cmd,raw",
+			opts:    csv.Options{Comma: ';', Quote: csv.QuoteAll, NoHeader: true},
+			out: `"101";"foo";"foo 101 ""udp"""
+"102";"";""
+"0";"";""
+`,
+		},
+		{
+			columns: "cmd,raw",
+			opts:    csv.Options{Quote: csv.QuoteNone, NoHeader: true},
+			out: `foo,foo 101 "udp"
+,
+,
+`,
+		},
+	}
+	for _, v := range tt {
+		cols, err := csv.ParseColumns(v.columns)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", v.columns, err)
+		}
+		v.opts.Columns = cols
+		var w strings.Builder
+		if err := csv.NewEncoder(&w, v.opts).Encode(netFiles0); err != nil {
+			t.Fatalf("%s: unexpected error: %v", v.columns, err)
+		}
+		if v.out != w.String() {
+			t.Fatalf("%s: unexpected output: wanted\n\"%s\",\nfound\n\"%s\"", v.columns, v.out, w.String())
+		}
+	}
 }
 
-func newUDPAddr(address string) net.Addr {
-	addr, err := net.ResolveUDPAddr("udp", address)
+func TestParseColumns(t *testing.T) {
+	t.Parallel()
+
+	cols, err := csv.ParseColumns(" PID, dport ")
 	if err != nil {
-		panic(err)
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(cols) != 2 || cols[0].Name != "pid" || cols[1].Name != "dport" {
+		t.Fatalf("Unexpected columns: %v", cols)
+	}
+	for _, v := range []string{"pid,foo", "", "pid,"} {
+		if _, err := csv.ParseColumns(v); err == nil {
+			t.Fatalf("expected error parsing columns %q", v)
+		}
 	}
-	return addr
+}
+
+func TestParseDelimiter(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		in  string
+		out rune
+	}{
+		{",", ','},
+		{";", ';'},
+		{"tab", '\t'},
+		{`\t`, '\t'},
+		{"|", '|'},
+	}
+	for _, v := range tt {
+		r, err := csv.ParseDelimiter(v.in)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", v.in, err)
+		}
+		if r != v.out {
+			t.Fatalf("%q: expected %q, found %q", v.in, v.out, r)
+		}
+	}
+	for _, v := range []string{"", ";;", `"`, "\n"} {
+		if _, err := csv.ParseDelimiter(v); err == nil {
+			t.Fatalf("expected error parsing delimiter %q", v)
+		}
+	}
+}
+
+var netFiles0 = []onf.ONF{
+	{
+		Raw:       `foo 101 "udp"`,
+		Cmd:       "foo",
+		Pid:       101,
+		Fd:        3,
+		User:      "dani",
+		Uid:       501,
+		Family:    onf.FamilyIPv4,
+		Proto:     "udp",
+		State:     onf.StateEstablished,
+		Src:       internal.NewAddr("udp", "192.168.0.61:54104"),
+		Dst:       internal.NewAddr("udp", "52.94.218.7:443"),
+		CreatedAt: time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC),
+	},
+	{Pid: 102, Fd: -1, Uid: -1, Family: onf.FamilyIPv6, Proto: "udp", Src: internal.NewAddr("udp", "[::1]:60051"), Dst: internal.NewAddr("udp", "[::1]:60052")},
+	// Addresses may be missing altogether.
+	{Fd: -1, Uid: -1, Proto: "tcp"},
 }
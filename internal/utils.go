@@ -74,3 +74,14 @@ func ParseNetAddr(network, addr string) (net.Addr, error) {
 		addr: addr,
 	}, nil
 }
+
+// NewAddr returns a net.Addr that reports "network" and "addr" as they
+// are, without validating them. Use it when the address has already been
+// decoded by other means, or to represent a missing address with an empty
+// "addr".
+func NewAddr(network, addr string) net.Addr {
+	return uncheckedAddr{
+		net:  strings.ToLower(network),
+		addr: addr,
+	}
+}
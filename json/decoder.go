@@ -0,0 +1,74 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package json
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/jecoz/lsaddr/onf"
+)
+
+// Decoder reads back the open network files written by Encoder or by
+// LineEncoder.
+type Decoder struct {
+	r io.Reader
+}
+
+// NewDecoder returns a Decoder reading from "r".
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads every value of the decoder's reader, which is either a
+// Document or an open network file, and returns the open network files
+// they contain. Concatenated documents are merged. Documents with a
+// schema version newer than SchemaVersion are rejected.
+func (d *Decoder) Decode() ([]onf.ONF, error) {
+	dec := json.NewDecoder(d.r)
+	set := []onf.ONF{}
+	for i := 1; ; i++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return set, nil
+		} else if err != nil {
+			return set, fmt.Errorf("unable to decode value %d: %w", i, err)
+		}
+		var probe struct {
+			SchemaVersion *int `json:"schema_version"`
+		}
+		if err := json.Unmarshal(raw, &probe); err != nil {
+			return set, fmt.Errorf("unable to decode value %d: %w", i, err)
+		}
+		if probe.SchemaVersion == nil {
+			var f onf.ONF
+			if err := json.Unmarshal(raw, &f); err != nil {
+				return set, fmt.Errorf("unable to decode open network file %d: %w", i, err)
+			}
+			set = append(set, f)
+			continue
+		}
+		if v := *probe.SchemaVersion; v > SchemaVersion {
+			return set, fmt.Errorf("unsupported schema version %d, expected %d or lower", v, SchemaVersion)
+		}
+		var doc Document
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return set, fmt.Errorf("unable to decode document %d: %w", i, err)
+		}
+		set = append(set, doc.Files...)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package json
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/onf"
+)
+
+// roundTripSet contains the addresses built by the backends, including
+// missing ones.
+var roundTripSet = []onf.ONF{
+	set[0],
+	{
+		Raw:       "nginx 900 \"tcp\" ->\n",
+		Cmd:       "nginx",
+		Pid:       900,
+		Fd:        6,
+		User:      "www",
+		Uid:       33,
+		Family:    onf.FamilyIPv6,
+		Proto:     "tcp",
+		State:     onf.StateListen,
+		Inode:     21445,
+		Src:       internal.NewAddr("tcp", "[fe80::1%eth0]:80"),
+		Dst:       internal.NewAddr("tcp", ""),
+		CreatedAt: time.Date(2019, 9, 1, 12, 0, 0, 500, time.UTC),
+	},
+	{Pid: 389, Fd: 8, Uid: -1, Proto: "udp", Src: internal.NewAddr("udp", "*:*"), Dst: internal.NewAddr("udp", "")},
+	{Pid: 102, Fd: -1, Uid: -1, Family: onf.FamilyUnix, Proto: "unix", Src: onf.NewUnixAddr("/run/foo.sock"), Dst: onf.NewUnixAddr(""), PeerPid: 103},
+	// Some backends do not report the protocol.
+	{Fd: -1, Uid: -1, Src: internal.NewAddr("tcp", "10.0.0.1:22"), Dst: internal.NewAddr("tcp", "10.0.0.2:51234")},
+}
+
+func TestDecode_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name string
+		enc  func(*strings.Builder) error
+	}{
+		{"json", func(w *strings.Builder) error { return NewEncoder(w).Encode(roundTripSet) }},
+		{"ndjson", func(w *strings.Builder) error { return NewLineEncoder(w).Encode(roundTripSet) }},
+	}
+	for _, v := range tt {
+		var w strings.Builder
+		if err := v.enc(&w); err != nil {
+			t.Fatalf("%s: unexpected error: %v", v.name, err)
+		}
+		found, err := NewDecoder(strings.NewReader(w.String())).Decode()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", v.name, err)
+		}
+		if !reflect.DeepEqual(roundTripSet, found) {
+			t.Fatalf("%s: unexpected round trip: wanted\n%v,\nfound\n%v", v.name, roundTripSet, found)
+		}
+	}
+}
+
+func TestDecode_Merge(t *testing.T) {
+	t.Parallel()
+
+	// Documents and single open network files may be concatenated.
+	var w strings.Builder
+	if err := NewEncoder(&w).Encode(roundTripSet[:1]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := NewLineEncoder(&w).Encode(roundTripSet[1:]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := NewEncoder(&w).Encode(roundTripSet); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	found, err := NewDecoder(strings.NewReader(w.String())).Decode()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	exp := append(append([]onf.ONF{}, roundTripSet...), roundTripSet...)
+	if !reflect.DeepEqual(exp, found) {
+		t.Fatalf("Unexpected set: wanted\n%v,\nfound\n%v", exp, found)
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tt := []string{
+		`{"schema_version": 2, "files": []}`,
+		`{"schema_version": 1, "files": {}}`,
+		`{"pid": "foo"}`,
+		`[`,
+	}
+	for i, v := range tt {
+		if _, err := NewDecoder(strings.NewReader(v)).Decode(); err == nil {
+			t.Fatalf("%d: expected error decoding %q", i, v)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package json encodes open network files as a single JSON document, or
+// as a stream of newline delimited JSON objects.
+package json
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jecoz/lsaddr/onf"
+)
+
+// SchemaVersion is the version of the documents produced by Encoder. It
+// is increased whenever a field is removed or changes its meaning.
+const SchemaVersion = 1
+
+// Document is the JSON document produced by Encoder.
+type Document struct {
+	SchemaVersion int       `json:"schema_version"`
+	Host          string    `json:"host"`
+	Timestamp     time.Time `json:"timestamp"`
+	Files         []onf.ONF `json:"files"`
+}
+
+// Encoder encodes open network files into a Document, which carries the
+// host they were collected on and the time of the encoding.
+type Encoder struct {
+	w    io.Writer
+	host string
+	now  func() time.Time
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	host, err := os.Hostname()
+	if err != nil {
+		log.Printf("unable to read host name: %v", err)
+	}
+	return &Encoder{w: w, host: host, now: time.Now}
+}
+
+// Encode writes "set" into encoder's writer as an indented Document.
+func (e *Encoder) Encode(set []onf.ONF) error {
+	if set == nil {
+		set = []onf.ONF{}
+	}
+	enc := json.NewEncoder(e.w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(Document{
+		SchemaVersion: SchemaVersion,
+		Host:          e.host,
+		Timestamp:     e.now(),
+		Files:         set,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to encode open network files: %w", err)
+	}
+	return nil
+}
+
+// LineEncoder encodes open network files as newline delimited JSON, one
+// object per line, which suits line oriented tools.
+type LineEncoder struct {
+	w io.Writer
+}
+
+func NewLineEncoder(w io.Writer) *LineEncoder {
+	return &LineEncoder{w: w}
+}
+
+// Encode writes each item of "set" into encoder's writer on its own
+// line. Some data may have been written to the writer even upon error.
+func (e *LineEncoder) Encode(set []onf.ONF) error {
+	enc := json.NewEncoder(e.w)
+	enc.SetEscapeHTML(false)
+	for _, v := range set {
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("unable to encode open network file %v: %w", v, err)
+		}
+	}
+	return nil
+}
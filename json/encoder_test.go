@@ -0,0 +1,118 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package json
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/onf"
+)
+
+var set = []onf.ONF{
+	{
+		Raw:       "foo 101 192.168.0.61:54104->52.94.218.7:443",
+		Cmd:       "foo",
+		Pid:       101,
+		Fd:        3,
+		Uid:       -1,
+		Family:    onf.FamilyIPv4,
+		Proto:     "udp",
+		Src:       internal.NewAddr("udp", "192.168.0.61:54104"),
+		Dst:       internal.NewAddr("udp", "52.94.218.7:443"),
+		CreatedAt: time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC),
+	},
+	{
+		Pid:       102,
+		Fd:        -1,
+		Uid:       -1,
+		Family:    onf.FamilyUnix,
+		Proto:     "unix",
+		State:     onf.StateListen,
+		Src:       onf.NewUnixAddr("/run/foo.sock"),
+		Dst:       onf.NewUnixAddr(""),
+		CreatedAt: time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC),
+	},
+}
+
+func TestEncode_JSON(t *testing.T) {
+	t.Parallel()
+
+	var w strings.Builder
+	enc := NewEncoder(&w)
+	enc.host = "box"
+	enc.now = func() time.Time { return time.Date(2019, 9, 1, 12, 0, 1, 0, time.UTC) }
+	if err := enc.Encode(set[1:]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expOut := `{
+  "schema_version": 1,
+  "host": "box",
+  "timestamp": "2019-09-01T12:00:01Z",
+  "files": [
+    {
+      "raw": "",
+      "cmd": "",
+      "pid": 102,
+      "fd": -1,
+      "user": "",
+      "uid": -1,
+      "family": "unix",
+      "proto": "unix",
+      "state": "LISTEN",
+      "inode": 0,
+      "src": {
+        "network": "unix",
+        "path": "/run/foo.sock"
+      },
+      "dst": null,
+      "peer_pid": 0,
+      "created_at": "2019-09-01T12:00:00Z"
+    }
+  ]
+}
+`
+	if expOut != w.String() {
+		t.Fatalf("Unexpected output: wanted\n\"%s\",\nfound\n\"%s\"", expOut, w.String())
+	}
+
+	w.Reset()
+	if err := enc.Encode(nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(w.String(), `"files": []`) {
+		t.Fatalf("Unexpected output for an empty set: %s", w.String())
+	}
+}
+
+func TestEncode_NDJSON(t *testing.T) {
+	t.Parallel()
+
+	var w strings.Builder
+	if err := NewLineEncoder(&w).Encode(set); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expOut := `{"raw":"foo 101 192.168.0.61:54104->52.94.218.7:443","cmd":"foo","pid":101,"fd":3,"user":"","uid":-1,"family":"ipv4","proto":"udp","state":"UNKNOWN","inode":0,"src":{"network":"udp","ip":"192.168.0.61","port":54104},"dst":{"network":"udp","ip":"52.94.218.7","port":443},"peer_pid":0,"created_at":"2019-09-01T12:00:00Z"}
+{"raw":"","cmd":"","pid":102,"fd":-1,"user":"","uid":-1,"family":"unix","proto":"unix","state":"LISTEN","inode":0,"src":{"network":"unix","path":"/run/foo.sock"},"dst":null,"peer_pid":0,"created_at":"2019-09-01T12:00:00Z"}
+`
+	if expOut != w.String() {
+		t.Fatalf("Unexpected output: wanted\n\"%s\",\nfound\n\"%s\"", expOut, w.String())
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package lsof
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/jecoz/lsaddr/internal"
+)
+
+// FieldSelection is the list of fields requested to lsof with its
+// `-F` option:
+// p: process id, c: command, u: user id, L: login name, f: file descriptor,
+// t: type, P: protocol, n: name, T: TCP/TPI information (state and queues,
+// when invoked with `-Tqs`). Unix domain sockets are recognised by their
+// "unix" type.
+const FieldSelection = "pcuLftPnT"
+
+// ParseFields expects "r" to contain the output of an
+// `lsof -i -n -P -Tqs -F pcuLftPnT` call. Each line contains a single
+// field, identified by its first character. Process fields are shared by
+// all the files that follow them, up to the next process set, while each
+// file set starts with an "f" field.
+// Files that cannot be parsed are skipped and logged. Returns an error
+// only if reading from "r" produces an error different from `io.EOF`.
+func ParseFields(r io.Reader) ([]OpenFile, error) {
+	set := []OpenFile{}
+	var proc, file *fieldSet
+	flush := func() {
+		if file == nil {
+			return
+		}
+		of, err := file.openFile(proc)
+		if err != nil {
+			log.Printf("skipping open file \"%s\": %v", file.raw(proc), err)
+		} else {
+			set = append(set, *of)
+		}
+		file = nil
+	}
+
+	err := internal.ScanLines(r, func(line string) error {
+		if line == "" {
+			return nil
+		}
+		id, value := line[0], line[1:]
+		switch id {
+		case 'p':
+			flush()
+			proc = &fieldSet{}
+		case 'f':
+			flush()
+			file = &fieldSet{}
+		}
+		switch {
+		case file != nil:
+			file.add(id, value, line)
+		case proc != nil:
+			proc.add(id, value, line)
+		default:
+			log.Printf("skipping field \"%s\": no process set", line)
+		}
+		return nil
+	})
+	flush()
+	return set, err
+}
+
+type fieldSet struct {
+	lines  []string
+	fields map[byte]string
+	tcp    map[string]string // T fields, e.g. ST=ESTABLISHED
+}
+
+func (s *fieldSet) add(id byte, value, line string) {
+	if s.fields == nil {
+		s.fields = make(map[byte]string)
+		s.tcp = make(map[string]string)
+	}
+	s.lines = append(s.lines, line)
+	if id == 'T' {
+		kv := strings.SplitN(value, "=", 2)
+		if len(kv) == 2 {
+			s.tcp[kv[0]] = kv[1]
+		}
+		return
+	}
+	s.fields[id] = value
+}
+
+func (s *fieldSet) raw(proc *fieldSet) string {
+	lines := s.lines
+	if proc != nil {
+		lines = append(append([]string{}, proc.lines...), s.lines...)
+	}
+	return strings.Join(lines, " ")
+}
+
+func (s *fieldSet) openFile(proc *fieldSet) (*OpenFile, error) {
+	if proc == nil {
+		return nil, fmt.Errorf("file set without process set")
+	}
+	pid, err := strconv.Atoi(proc.fields['p'])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing pid: %w", err)
+	}
+	uid := -1
+	if raw, ok := proc.fields['u']; ok {
+		if uid, err = strconv.Atoi(raw); err != nil {
+			return nil, fmt.Errorf("error parsing uid: %w", err)
+		}
+	}
+	user := proc.fields['L']
+	if user == "" && uid >= 0 {
+		user = strconv.Itoa(uid)
+	}
+
+	proto := s.fields['P']
+	var src, dst net.Addr
+	if s.fields['t'] == "unix" {
+		proto = "unix"
+		src, dst = ParseUnixName(s.fields['n'])
+	} else if src, dst, err = ParseName(proto, s.fields['n']); err != nil {
+		return nil, fmt.Errorf("error parsing name: %w", err)
+	}
+	return &OpenFile{
+		Raw:       s.raw(proc),
+		Command:   proc.fields['c'],
+		Pid:       pid,
+		User:      user,
+		Uid:       uid,
+		Fd:        s.fields['f'],
+		Type:      s.fields['t'],
+		Protocol:  proto,
+		State:     s.tcp["ST"],
+		RecvQueue: s.queue("QR"),
+		SendQueue: s.queue("QS"),
+		SrcAddr:   src,
+		DstAddr:   dst,
+	}, nil
+}
+
+// ParseUnixName parses the name field of a unix domain socket, which
+// contains its path, if any, followed by additional information such as
+// its type, e.g. "/run/docker.sock type=STREAM". On macOS the name
+// contains the kernel address of the peer instead, e.g.
+// "->0x25c5bf0997ca88e3", which is not meaningful to users and dropped.
+func ParseUnixName(name string) (net.Addr, net.Addr) {
+	path := name
+	if i := strings.Index(path, " type="); i >= 0 {
+		path = path[:i]
+	}
+	if strings.HasPrefix(path, "type=") || strings.HasPrefix(path, "->") {
+		path = ""
+	}
+	return internal.NewAddr("unix", path), internal.NewAddr("unix", "")
+}
+
+func (s *fieldSet) queue(key string) int {
+	n, err := strconv.Atoi(s.tcp[key])
+	if err != nil {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,137 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package lsof
+
+import (
+	"bytes"
+	"testing"
+)
+
+const fieldsExample = `p614
+cDropbox
+u501
+Ldanielmorandini
+f236
+tIPv4
+PTCP
+n192.168.0.61:58122->162.125.66.7:443
+TST=ESTABLISHED
+TQR=0
+TQS=31
+f247
+tIPv6
+PUDP
+n*:5353
+p11778
+cGoogle Chrome He
+u501
+Ldanielmorandini
+f128
+tIPv4
+PTCP
+n127.0.0.1:5432
+TST=LISTEN
+f129
+tIPv4
+PTCP
+p676
+cpostgres
+u70
+f10
+tIPv6
+PUDP
+n[::1]:60051->[::1]:60052
+`
+
+func TestParseFields(t *testing.T) {
+	t.Parallel()
+
+	set, err := ParseFields(bytes.NewBufferString(fieldsExample))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 4 {
+		t.Fatalf("Unexpected set length: wanted 4, found %d: %v", len(set), set)
+	}
+
+	of := set[0]
+	assert(t, "Dropbox", of.Command)
+	assert(t, 614, of.Pid)
+	assert(t, "danielmorandini", of.User)
+	assert(t, 501, of.Uid)
+	assert(t, "236", of.Fd)
+	assert(t, "IPv4", of.Type)
+	assert(t, "TCP", of.Protocol)
+	assert(t, "ESTABLISHED", of.State)
+	assert(t, 0, of.RecvQueue)
+	assert(t, 31, of.SendQueue)
+	assert(t, "192.168.0.61:58122", of.SrcAddr.String())
+	assert(t, "162.125.66.7:443", of.DstAddr.String())
+	assert(t, "tcp", of.SrcAddr.Network())
+
+	of = set[1]
+	assert(t, "Dropbox", of.Command)
+	assert(t, "247", of.Fd)
+	assert(t, "*:5353", of.SrcAddr.String())
+	assert(t, "udp", of.SrcAddr.Network())
+	assert(t, "", of.DstAddr.String())
+	assert(t, -1, of.RecvQueue)
+
+	of = set[2]
+	assert(t, "Google Chrome He", of.Command)
+	assert(t, "LISTEN", of.State)
+
+	of = set[3]
+	assert(t, "postgres", of.Command)
+	assert(t, "70", of.User)
+	assert(t, "[::1]:60052", of.DstAddr.String())
+}
+
+const unixFieldsExample = `p612
+cdbus-daemon
+u104
+Lmessagebus
+f12
+tunix
+n/run/dbus/system_bus_socket type=STREAM
+f13
+tunix
+ntype=STREAM
+p788
+csshd
+f4
+tunix
+n->0xffff9a0c3c4d8800
+`
+
+func TestParseFields_Unix(t *testing.T) {
+	t.Parallel()
+
+	set, err := ParseFields(bytes.NewBufferString(unixFieldsExample))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 3 {
+		t.Fatalf("Unexpected set length: wanted 3, found %d: %v", len(set), set)
+	}
+	assert(t, "unix", set[0].Protocol)
+	assert(t, "unix", set[0].SrcAddr.Network())
+	assert(t, "/run/dbus/system_bus_socket", set[0].SrcAddr.String())
+	assert(t, "", set[0].DstAddr.String())
+	assert(t, "", set[1].SrcAddr.String())
+	assert(t, "", set[2].SrcAddr.String())
+	assert(t, "sshd", set[2].Command)
+}
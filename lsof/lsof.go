@@ -17,46 +17,61 @@ package lsof
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/jecoz/lsaddr/internal"
-	"gopkg.in/pipe.v2"
+	"github.com/jecoz/lsaddr/tool"
 )
 
 type OpenFile struct {
-	Raw     string
-	Command string
-	Pid     int
-	User    string
-	Fd      string
-	Type    string
-	Device  string
-	State   string   // (ENSTABLISHED), (LISTEN), ...
-	SrcAddr net.Addr // Source address
-	DstAddr net.Addr // Destination address
+	Raw       string
+	Command   string
+	Pid       int
+	User      string
+	Uid       int // -1 if unknown
+	Fd        string
+	Type      string
+	Device    string
+	Protocol  string   // TCP, UDP, ...
+	State     string   // (ENSTABLISHED), (LISTEN), ... parentheses are not present in field output
+	RecvQueue int      // -1 if unknown
+	SendQueue int      // -1 if unknown
+	SrcAddr   net.Addr // Source address
+	DstAddr   net.Addr // Destination address
 }
 
-func Run() ([]OpenFile, error) {
-	log.Printf("Executing: lsof -i -n -P")
-	p := pipe.Exec("lsof", "-i", "-n", "-P")
+// Run executes ``lsof -i -n -P -Tqs -F pcuLftPnT'', configured with "opts",
+// and parses its machine readable output with ``ParseFields''.
+// Execution errors are of type *tool.Error.
+func Run(ctx context.Context, opts tool.Options) ([]OpenFile, error) {
+	out, err := tool.Run(ctx, opts, "lsof", "-i", "-n", "-P", "-Tqs", "-F", FieldSelection)
+	if err != nil {
+		return []OpenFile{}, fmt.Errorf("unable to run lsof: %w", err)
+	}
+	return ParseFields(bytes.NewBuffer(out))
+}
 
-	acc := []OpenFile{}
-	out, err := pipe.OutputTimeout(p, time.Millisecond*100)
+// RunUnix executes ``lsof -U -n -P -F pcuLftn'', configured with "opts",
+// and parses the unix domain sockets found with ``ParseFields''.
+// Execution errors are of type *tool.Error.
+func RunUnix(ctx context.Context, opts tool.Options) ([]OpenFile, error) {
+	out, err := tool.Run(ctx, opts, "lsof", "-U", "-n", "-P", "-F", FieldSelection)
 	if err != nil {
-		return acc, fmt.Errorf("unable to run lsof: %w", err)
+		return []OpenFile{}, fmt.Errorf("unable to run lsof: %w", err)
 	}
-	buf := bytes.NewBuffer(out)
-	return ParseOutput(buf)
+	return ParseFields(bytes.NewBuffer(out))
 }
 
 // ParseOutput expects "r" to contain the output of
-// an ``lsof -i -n -P'' call. The output is splitted into each new line,
+// an ``lsof -i -n -P'' call, and is meant to be used on captured outputs
+// only, as commands containing spaces are not parsed correctly.
+// The output is splitted into each new line,
 // and each line that ``ParseOpenFile'' is able to parse
 // is appended to the final output.
 // Returns an error only if reading from "r" produces an error
@@ -108,13 +123,17 @@ func ParseOpenFile(line string) (*OpenFile, error) {
 	}
 
 	of := &OpenFile{
-		Raw:     line,
-		Command: chunks[0],
-		Pid:     pid,
-		User:    chunks[2],
-		Fd:      chunks[3],
-		Type:    chunks[4],
-		Device:  chunks[5],
+		Raw:       line,
+		Command:   chunks[0],
+		Pid:       pid,
+		User:      chunks[2],
+		Uid:       -1,
+		Fd:        chunks[3],
+		Type:      chunks[4],
+		Device:    chunks[5],
+		Protocol:  chunks[7],
+		RecvQueue: -1,
+		SendQueue: -1,
 	}
 	src, dst, err := ParseName(chunks[7], chunks[8])
 	if err != nil {
@@ -139,12 +158,12 @@ func ParseName(node, name string) (net.Addr, net.Addr, error) {
 	if len(chunks) == 0 {
 		return nil, nil, fmt.Errorf("unable to split name by ->")
 	}
-	src, err := internal.ParseNetAddr(node, chunks[0])
+	src, err := parseNetAddr(node, chunks[0])
 	if err != nil {
 		return nil, nil, err
 	}
 	if len(chunks) == 1 {
-		return src, addr{}, nil
+		return src, internal.NewAddr(node, ""), nil
 	}
 	dst, err := internal.ParseNetAddr(node, chunks[1])
 	if err != nil {
@@ -153,16 +172,12 @@ func ParseName(node, name string) (net.Addr, net.Addr, error) {
 	return src, dst, nil
 }
 
-// addr is a net.Addr implementation.
-type addr struct {
-	addr string
-	net  string
-}
-
-func (a addr) String() string {
-	return a.addr
-}
-
-func (a addr) Network() string {
-	return a.net
+// parseNetAddr works like ``internal.ParseNetAddr'', but also accepts
+// the "*" wildcard host lsof uses for sockets bound to every interface,
+// e.g. "*:5353".
+func parseNetAddr(node, name string) (net.Addr, error) {
+	if strings.HasPrefix(name, "*:") {
+		return internal.NewAddr(node, name), nil
+	}
+	return internal.ParseNetAddr(node, name)
 }
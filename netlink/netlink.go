@@ -0,0 +1,374 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package netlink collects sockets from the Linux kernel using the
+// NETLINK_SOCK_DIAG protocol, the same interface used by `ss`. The
+// encoding and decoding of the messages is available on every platform,
+// while Run and RunUnix are implemented on linux only.
+package netlink
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/procfs"
+)
+
+// Constants from linux/netlink.h, linux/sock_diag.h and linux/inet_diag.h.
+const (
+	afInet  = 2
+	afInet6 = 10
+
+	ipprotoTCP = 6
+	ipprotoUDP = 17
+
+	nlmsgError = 2
+	nlmsgDone  = 3
+
+	nlmFRequest = 0x1
+	nlmFDump    = 0x300
+
+	sockDiagByFamily = 20
+
+	inetDiagReqBytecode = 1
+	inetDiagInfo        = 2
+
+	inetDiagBcSGE = 2
+	inetDiagBcSLE = 3
+	inetDiagBcDGE = 4
+	inetDiagBcDLE = 5
+
+	sizeofNlMsghdr     = 16
+	sizeofInetDiagReq  = 56
+	sizeofInetDiagMsg  = 72
+	sizeofRtAttr       = 4
+	sizeofInetDiagBcOp = 4
+)
+
+// PortRange is an inclusive range of ports, e.g. {Lo: 0, Hi: 0} matches
+// port 0 only.
+type PortRange struct {
+	Lo, Hi uint16
+}
+
+// Any reports whether "r" matches every port.
+func (r PortRange) Any() bool {
+	return r.Lo == 0 && r.Hi == 0xffff
+}
+
+// Contains reports whether "port" is in the range.
+func (r PortRange) Contains(port int) bool {
+	return int(r.Lo) <= port && port <= int(r.Hi)
+}
+
+func (r PortRange) String() string {
+	if r.Lo == r.Hi {
+		return strconv.Itoa(int(r.Lo))
+	}
+	return fmt.Sprintf("%d-%d", r.Lo, r.Hi)
+}
+
+// Filter is evaluated by the kernel while dumping sockets, so that only
+// the matching ones are sent back to userspace.
+type Filter struct {
+	States []procfs.State // empty means every state
+	Sport  *PortRange     // local port, nil means any
+	Dport  *PortRange     // remote port, nil means any
+}
+
+func (f Filter) states() uint32 {
+	if len(f.States) == 0 {
+		return 0xffffffff
+	}
+	var mask uint32
+	for _, v := range f.States {
+		mask |= 1 << uint(v)
+	}
+	return mask
+}
+
+// bytecode compiles the port conditions of the filter into an
+// inet_diag bytecode program, which the kernel runs against each socket.
+// The socket is accepted when the program reaches its end, and rejected
+// when a condition jumps past it.
+func (f Filter) bytecode() []byte {
+	type cond struct {
+		code uint8
+		port uint16
+	}
+	conds := []cond{}
+	if f.Sport != nil && !f.Sport.Any() {
+		conds = append(conds, cond{inetDiagBcSGE, f.Sport.Lo}, cond{inetDiagBcSLE, f.Sport.Hi})
+	}
+	if f.Dport != nil && !f.Dport.Any() {
+		conds = append(conds, cond{inetDiagBcDGE, f.Dport.Lo}, cond{inetDiagBcDLE, f.Dport.Hi})
+	}
+	if len(conds) == 0 {
+		return nil
+	}
+
+	const opLen = sizeofInetDiagBcOp * 2
+	total := len(conds) * opLen
+	b := make([]byte, 0, total)
+	for i, c := range conds {
+		rejectJump := total - i*opLen + 4
+		b = append(b, c.code, opLen)
+		b = appendUint16(b, uint16(rejectJump))
+		// The second operation carries the port in its "no" field.
+		b = append(b, 0, 0)
+		b = appendUint16(b, c.port)
+	}
+	return b
+}
+
+// TCPInfo is a subset of the kernel's struct tcp_info.
+type TCPInfo struct {
+	State         uint8
+	Retransmits   uint8
+	Rto           uint32 // usec
+	SndMss        uint32
+	RcvMss        uint32
+	Unacked       uint32
+	Lost          uint32
+	Retrans       uint32
+	Rtt           uint32 // usec
+	RttVar        uint32 // usec
+	SndCwnd       uint32
+	TotalRetrans  uint32
+	BytesAcked    uint64
+	BytesReceived uint64
+}
+
+// Socket is a socket as reported by sock_diag.
+type Socket struct {
+	Network string // tcp, udp
+	IPv6    bool
+	SrcAddr net.Addr
+	DstAddr net.Addr
+	State   procfs.State
+	RxQueue uint32
+	TxQueue uint32
+	Uid     int
+	Inode   uint64
+	Info    *TCPInfo // tcp only, when provided by the kernel
+}
+
+func newHeader(size int, seq uint32) []byte {
+	b := make([]byte, 0, size)
+	// struct nlmsghdr
+	b = appendUint32(b, uint32(size))
+	b = appendUint16(b, sockDiagByFamily)
+	b = appendUint16(b, nlmFRequest|nlmFDump)
+	b = appendUint32(b, seq)
+	return appendUint32(b, 0)
+}
+
+// NewRequest returns the netlink message that asks the kernel to dump
+// the sockets of "family" and "proto" which match "f".
+func NewRequest(family, proto uint8, f Filter, seq uint32) []byte {
+	bc := f.bytecode()
+	size := sizeofNlMsghdr + sizeofInetDiagReq
+	if len(bc) > 0 {
+		size += sizeofRtAttr + len(bc)
+	}
+
+	b := newHeader(size, seq)
+
+	// struct inet_diag_req_v2
+	var ext uint8
+	if proto == ipprotoTCP {
+		ext |= 1 << (inetDiagInfo - 1)
+	}
+	b = append(b, family, proto, ext, 0)
+	b = appendUint32(b, f.states())
+	b = append(b, make([]byte, 48)...) // struct inet_diag_sockid, wildcard
+
+	if len(bc) > 0 {
+		b = appendUint16(b, uint16(sizeofRtAttr+len(bc)))
+		b = appendUint16(b, inetDiagReqBytecode)
+		b = append(b, bc...)
+	}
+	return b
+}
+
+// ParseMessages decodes a buffer of netlink messages received in response
+// to a request produced by NewRequest. "done" is true when the end of the
+// dump has been reached.
+func ParseMessages(b []byte, network string) (set []Socket, done bool, err error) {
+	set = []Socket{}
+	done, err = walkMessages(b, func(data []byte) error {
+		s, err := ParseSocket(data, network)
+		if err != nil {
+			return err
+		}
+		set = append(set, *s)
+		return nil
+	})
+	return set, done, err
+}
+
+// walkMessages calls "f" with the payload of each sock_diag message
+// contained in "b", handling errors and the end of the dump.
+func walkMessages(b []byte, f func([]byte) error) (done bool, err error) {
+	for len(b) >= sizeofNlMsghdr {
+		l := int(internal.NativeEndian.Uint32(b[0:4]))
+		typ := internal.NativeEndian.Uint16(b[4:6])
+		if l < sizeofNlMsghdr || l > len(b) {
+			return false, fmt.Errorf("invalid netlink message length %d", l)
+		}
+		data := b[sizeofNlMsghdr:l]
+		b = b[align(l):]
+
+		switch typ {
+		case nlmsgDone:
+			return true, nil
+		case nlmsgError:
+			if len(data) < 4 {
+				return false, fmt.Errorf("truncated netlink error message")
+			}
+			errno := int32(internal.NativeEndian.Uint32(data[0:4]))
+			if errno == 0 {
+				continue
+			}
+			return false, &Errno{Code: int(-errno)}
+		case sockDiagByFamily:
+			if err := f(data); err != nil {
+				return false, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// Errno is an error returned by the kernel in response to a request.
+type Errno struct {
+	Code int
+}
+
+func (e *Errno) Error() string {
+	return "netlink: kernel returned errno " + strconv.Itoa(e.Code)
+}
+
+// ParseSocket decodes a struct inet_diag_msg, followed by its attributes.
+func ParseSocket(b []byte, network string) (*Socket, error) {
+	if len(b) < sizeofInetDiagMsg {
+		return nil, fmt.Errorf("truncated inet_diag_msg: %d bytes", len(b))
+	}
+	family := b[0]
+	var iplen int
+	switch family {
+	case afInet:
+		iplen = net.IPv4len
+	case afInet6:
+		iplen = net.IPv6len
+	default:
+		return nil, fmt.Errorf("unexpected address family %d", family)
+	}
+
+	// struct inet_diag_sockid starts at offset 4. Ports and addresses
+	// are in network byte order.
+	sport := binary.BigEndian.Uint16(b[4:6])
+	dport := binary.BigEndian.Uint16(b[6:8])
+	src := net.IP(append([]byte{}, b[8:8+iplen]...))
+	dst := net.IP(append([]byte{}, b[24:24+iplen]...))
+
+	s := &Socket{
+		Network: network,
+		IPv6:    family == afInet6,
+		SrcAddr: newAddr(network, src, sport),
+		DstAddr: newAddr(network, dst, dport),
+		State:   procfs.State(b[1]),
+		RxQueue: internal.NativeEndian.Uint32(b[56:60]),
+		TxQueue: internal.NativeEndian.Uint32(b[60:64]),
+		Uid:     int(internal.NativeEndian.Uint32(b[64:68])),
+		Inode:   uint64(internal.NativeEndian.Uint32(b[68:72])),
+	}
+
+	attrs := b[sizeofInetDiagMsg:]
+	for len(attrs) >= sizeofRtAttr {
+		l := int(internal.NativeEndian.Uint16(attrs[0:2]))
+		typ := internal.NativeEndian.Uint16(attrs[2:4])
+		if l < sizeofRtAttr || l > len(attrs) {
+			return nil, fmt.Errorf("invalid attribute length %d", l)
+		}
+		if typ == inetDiagInfo {
+			s.Info = parseTCPInfo(attrs[sizeofRtAttr:l])
+		}
+		attrs = attrs[align(l):]
+	}
+	return s, nil
+}
+
+func newAddr(network string, ip net.IP, port uint16) net.Addr {
+	if ip.IsUnspecified() && port == 0 {
+		return internal.NewAddr(network, "")
+	}
+	return internal.NewAddr(network, net.JoinHostPort(ip.String(), strconv.Itoa(int(port))))
+}
+
+// parseTCPInfo decodes the fields of struct tcp_info that are available
+// in "b", which is shorter on older kernels.
+func parseTCPInfo(b []byte) *TCPInfo {
+	if len(b) < 8 {
+		return nil
+	}
+	u32 := func(off int) uint32 {
+		if off+4 > len(b) {
+			return 0
+		}
+		return internal.NativeEndian.Uint32(b[off : off+4])
+	}
+	u64 := func(off int) uint64 {
+		if off+8 > len(b) {
+			return 0
+		}
+		return internal.NativeEndian.Uint64(b[off : off+8])
+	}
+	return &TCPInfo{
+		State:         b[0],
+		Retransmits:   b[2],
+		Rto:           u32(8),
+		SndMss:        u32(16),
+		RcvMss:        u32(20),
+		Unacked:       u32(24),
+		Lost:          u32(32),
+		Retrans:       u32(36),
+		Rtt:           u32(68),
+		RttVar:        u32(72),
+		SndCwnd:       u32(80),
+		TotalRetrans:  u32(100),
+		BytesAcked:    u64(120),
+		BytesReceived: u64(128),
+	}
+}
+
+func align(l int) int {
+	return (l + 3) &^ 3
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	var buf [2]byte
+	internal.NativeEndian.PutUint16(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	internal.NativeEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
@@ -0,0 +1,151 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package netlink
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+	"time"
+)
+
+// pollInterval bounds the time spent waiting for a sock_diag response
+// before checking whether the context is done.
+const pollInterval = time.Millisecond * 200
+
+// Run dumps the tcp and udp sockets, both IPv4 and IPv6, matching "f".
+// The dump is aborted when "ctx" is done.
+func Run(ctx context.Context, f Filter) ([]Socket, error) {
+	fd, err := open()
+	if err != nil {
+		return []Socket{}, err
+	}
+	defer syscall.Close(fd)
+
+	acc := []Socket{}
+	targets := []struct {
+		family, proto uint8
+		network       string
+	}{
+		{afInet, ipprotoTCP, "tcp"},
+		{afInet6, ipprotoTCP, "tcp"},
+		{afInet, ipprotoUDP, "udp"},
+		{afInet6, ipprotoUDP, "udp"},
+	}
+	for i, t := range targets {
+		log.Printf("Dumping sock_diag family: %d, protocol: %d", t.family, t.proto)
+		err := dump(ctx, fd, NewRequest(t.family, t.proto, f, uint32(i+1)), func(b []byte) (bool, error) {
+			set, done, err := ParseMessages(b, t.network)
+			acc = append(acc, set...)
+			return done, err
+		})
+		if err != nil {
+			if errno, ok := err.(*Errno); ok && t.family == afInet6 && errno.Code == int(syscall.ENOENT) {
+				// IPv6 is not available.
+				continue
+			}
+			return acc, err
+		}
+	}
+	return acc, nil
+}
+
+// RunUnix dumps the unix domain sockets, together with their peers.
+// The dump is aborted when "ctx" is done.
+func RunUnix(ctx context.Context) ([]UnixSocket, error) {
+	fd, err := open()
+	if err != nil {
+		return []UnixSocket{}, err
+	}
+	defer syscall.Close(fd)
+
+	log.Printf("Dumping sock_diag family: %d", afUnix)
+	acc := []UnixSocket{}
+	err = dump(ctx, fd, NewUnixRequest(1), func(b []byte) (bool, error) {
+		set, done, err := ParseUnixMessages(b)
+		acc = append(acc, set...)
+		return done, err
+	})
+	return acc, err
+}
+
+func open() (int, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_INET_DIAG)
+	if err != nil {
+		return -1, fmt.Errorf("unable to open netlink socket: %w", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("unable to bind netlink socket: %w", err)
+	}
+	return fd, nil
+}
+
+// dump sends "req" and passes each response to "parse", until it
+// reports that the dump is done. Receives time out according to the
+// deadline of "ctx", or after pollInterval, so that a blocked receive
+// does not outlive "ctx".
+func dump(ctx context.Context, fd int, req []byte, parse func([]byte) (bool, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := syscall.Sendto(fd, req, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return fmt.Errorf("unable to send sock_diag request: %w", err)
+	}
+
+	buf := make([]byte, os.Getpagesize()*8)
+	for {
+		if err := setRecvTimeout(ctx, fd); err != nil {
+			return err
+		}
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err == syscall.EAGAIN || err == syscall.EINTR {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("unable to receive sock_diag response: %w", err)
+		}
+		done, err := parse(buf[:n])
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// setRecvTimeout sets SO_RCVTIMEO to the time left before the deadline
+// of "ctx", capped to pollInterval to notice cancellations too.
+func setRecvTimeout(ctx context.Context, fd int) error {
+	timeout := pollInterval
+	if deadline, ok := ctx.Deadline(); ok {
+		left := time.Until(deadline)
+		if left <= 0 {
+			return context.DeadlineExceeded
+		}
+		if left < timeout {
+			timeout = left
+		}
+	}
+	tv := syscall.NsecToTimeval(timeout.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return fmt.Errorf("unable to set netlink socket timeout: %w", err)
+	}
+	return nil
+}
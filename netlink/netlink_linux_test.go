@@ -0,0 +1,36 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// +build linux
+
+package netlink
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRun_Canceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Run(ctx, Filter{}); err != context.Canceled {
+		t.Fatalf("Unexpected error: wanted %v, found %v", context.Canceled, err)
+	}
+	if _, err := RunUnix(ctx); err != context.Canceled {
+		t.Fatalf("Unexpected error: wanted %v, found %v", context.Canceled, err)
+	}
+}
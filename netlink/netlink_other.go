@@ -0,0 +1,33 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// +build !linux
+
+package netlink
+
+import (
+	"context"
+	"errors"
+)
+
+// Run is only supported on linux.
+func Run(ctx context.Context, f Filter) ([]Socket, error) {
+	return []Socket{}, errors.New("netlink: sock_diag is only available on linux")
+}
+
+// RunUnix is only supported on linux.
+func RunUnix(ctx context.Context) ([]UnixSocket, error) {
+	return []UnixSocket{}, errors.New("netlink: sock_diag is only available on linux")
+}
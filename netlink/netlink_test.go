@@ -0,0 +1,186 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package netlink
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/procfs"
+)
+
+func TestNewRequest(t *testing.T) {
+	t.Parallel()
+
+	req := NewRequest(afInet, ipprotoTCP, Filter{}, 7)
+	assert(t, sizeofNlMsghdr+sizeofInetDiagReq, len(req))
+	assert(t, uint32(len(req)), internal.NativeEndian.Uint32(req[0:4]))
+	assert(t, uint16(sockDiagByFamily), internal.NativeEndian.Uint16(req[4:6]))
+	assert(t, uint32(7), internal.NativeEndian.Uint32(req[8:12]))
+	assert(t, []byte{afInet, ipprotoTCP, 1 << (inetDiagInfo - 1), 0}, req[16:20])
+	assert(t, uint32(0xffffffff), internal.NativeEndian.Uint32(req[20:24]))
+
+	f := Filter{
+		States: []procfs.State{procfs.Listen, procfs.Established},
+		Dport:  &PortRange{Lo: 443, Hi: 443},
+	}
+	req = NewRequest(afInet6, ipprotoUDP, f, 1)
+	assert(t, sizeofNlMsghdr+sizeofInetDiagReq+sizeofRtAttr+16, len(req))
+	assert(t, []byte{afInet6, ipprotoUDP, 0, 0}, req[16:20])
+	assert(t, uint32(1<<10|1<<1), internal.NativeEndian.Uint32(req[20:24]))
+
+	attr := req[sizeofNlMsghdr+sizeofInetDiagReq:]
+	assert(t, uint16(20), internal.NativeEndian.Uint16(attr[0:2]))
+	assert(t, uint16(inetDiagReqBytecode), internal.NativeEndian.Uint16(attr[2:4]))
+}
+
+func TestFilterBytecode(t *testing.T) {
+	t.Parallel()
+
+	assert(t, 0, len(Filter{}.bytecode()))
+	assert(t, 0, len(Filter{Sport: &PortRange{0, 0xffff}}.bytecode()))
+
+	bc := Filter{Sport: &PortRange{Lo: 30000, Hi: 32767}}.bytecode()
+	assert(t, 16, len(bc))
+	// sport >= 30000, otherwise jump past the end.
+	assert(t, []byte{inetDiagBcSGE, 8}, bc[0:2])
+	assert(t, uint16(20), internal.NativeEndian.Uint16(bc[2:4]))
+	assert(t, uint16(30000), internal.NativeEndian.Uint16(bc[6:8]))
+	// sport <= 32767
+	assert(t, []byte{inetDiagBcSLE, 8}, bc[8:10])
+	assert(t, uint16(12), internal.NativeEndian.Uint16(bc[10:12]))
+	assert(t, uint16(32767), internal.NativeEndian.Uint16(bc[14:16]))
+
+	bc = Filter{Dport: &PortRange{Lo: 1024, Hi: 0xffff}}.bytecode()
+	assert(t, []byte{inetDiagBcDGE, 8}, bc[0:2])
+	assert(t, []byte{inetDiagBcDLE, 8}, bc[8:10])
+	assert(t, uint16(0xffff), internal.NativeEndian.Uint16(bc[14:16]))
+
+	// Port 0 is a port like any other.
+	bc = Filter{Dport: &PortRange{}}.bytecode()
+	assert(t, 16, len(bc))
+	assert(t, uint16(0), internal.NativeEndian.Uint16(bc[14:16]))
+}
+
+func TestParseMessages(t *testing.T) {
+	t.Parallel()
+
+	var b []byte
+	b = append(b, message(sockDiagByFamily, diagMsg(afInet, procfs.Established, "192.168.0.61", 51805, "35.186.14.47", 443, true))...)
+	b = append(b, message(sockDiagByFamily, diagMsg(afInet6, procfs.Listen, "::", 22, "::", 0, false))...)
+	b = append(b, message(nlmsgDone, make([]byte, 4))...)
+
+	set, done, err := ParseMessages(b, "tcp")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, true, done)
+	assert(t, 2, len(set))
+
+	assert(t, "192.168.0.61:51805", set[0].SrcAddr.String())
+	assert(t, "35.186.14.47:443", set[0].DstAddr.String())
+	assert(t, "tcp", set[0].SrcAddr.Network())
+	assert(t, procfs.Established, set[0].State)
+	assert(t, 1000, set[0].Uid)
+	assert(t, uint64(184033), set[0].Inode)
+	if set[0].Info == nil {
+		t.Fatalf("Expected tcp_info to be decoded")
+	}
+	assert(t, uint32(175), set[0].Info.Rtt)
+	assert(t, uint64(2665363), set[0].Info.BytesAcked)
+
+	assert(t, false, set[0].IPv6)
+	assert(t, true, set[1].IPv6)
+	assert(t, "[::]:22", set[1].SrcAddr.String())
+	assert(t, "", set[1].DstAddr.String())
+	assert(t, procfs.Listen, set[1].State)
+	if set[1].Info != nil {
+		t.Fatalf("Unexpected tcp_info: %+v", set[1].Info)
+	}
+}
+
+func TestParseMessages_Error(t *testing.T) {
+	t.Parallel()
+
+	errno := make([]byte, 4)
+	internal.NativeEndian.PutUint32(errno, 0xfffffffe) // -ENOENT
+	_, done, err := ParseMessages(message(nlmsgError, errno), "udp")
+	if err == nil {
+		t.Fatalf("Expected error")
+	}
+	assert(t, false, done)
+	e, ok := err.(*Errno)
+	if !ok {
+		t.Fatalf("Unexpected error type: %T", err)
+	}
+	assert(t, 2, e.Code)
+
+	_, _, err = ParseMessages([]byte{0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, "udp")
+	if err == nil {
+		t.Fatalf("Expected error on invalid message length")
+	}
+}
+
+func message(typ uint16, data []byte) []byte {
+	b := appendUint32(nil, uint32(sizeofNlMsghdr+len(data)))
+	b = appendUint16(b, typ)
+	b = appendUint16(b, 0)
+	b = appendUint32(b, 1)
+	b = appendUint32(b, 0)
+	b = append(b, data...)
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func diagMsg(family uint8, state procfs.State, src string, sport uint16, dst string, dport uint16, info bool) []byte {
+	b := make([]byte, sizeofInetDiagMsg)
+	b[0] = family
+	b[1] = uint8(state)
+	b[4], b[5] = uint8(sport>>8), uint8(sport)
+	b[6], b[7] = uint8(dport>>8), uint8(dport)
+	ip := func(s string) net.IP {
+		ip := net.ParseIP(s)
+		if family == afInet {
+			return ip.To4()
+		}
+		return ip
+	}
+	copy(b[8:24], ip(src))
+	copy(b[24:40], ip(dst))
+	internal.NativeEndian.PutUint32(b[64:68], 1000)
+	internal.NativeEndian.PutUint32(b[68:72], 184033)
+	if !info {
+		return b
+	}
+
+	ti := make([]byte, 136)
+	ti[0] = uint8(state)
+	internal.NativeEndian.PutUint32(ti[68:72], 175)
+	internal.NativeEndian.PutUint64(ti[120:128], 2665363)
+	b = appendUint16(b, uint16(sizeofRtAttr+len(ti)))
+	b = appendUint16(b, inetDiagInfo)
+	return append(b, ti...)
+}
+
+func assert(t *testing.T, exp, x interface{}) {
+	if !reflect.DeepEqual(exp, x) {
+		t.Fatalf("Assert failed: expected %v, found %v", exp, x)
+	}
+}
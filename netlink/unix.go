@@ -0,0 +1,143 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package netlink
+
+import (
+	"fmt"
+
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/procfs"
+)
+
+// Constants from linux/unix_diag.h.
+const (
+	afUnix = 1
+
+	udiagShowName = 0x1
+	udiagShowPeer = 0x4
+	udiagShowUid  = 0x40
+
+	unixDiagName = 0
+	unixDiagPeer = 2
+	unixDiagUid  = 7
+
+	sizeofUnixDiagReq = 24
+	sizeofUnixDiagMsg = 16
+)
+
+// Unix socket types, see include/linux/net.h.
+var unixTypes = map[uint8]string{
+	1: "stream",
+	2: "dgram",
+	3: "raw",
+	4: "rdm",
+	5: "seqpacket",
+}
+
+// UnixSocket is a unix domain socket as reported by unix_diag.
+type UnixSocket struct {
+	Path  string // empty for unnamed sockets, starts with "@" for abstract ones
+	Type  string // stream, dgram, seqpacket
+	State procfs.State
+	Inode uint64
+	Peer  uint64 // inode of the peer socket, 0 if not connected
+	Uid   int    // -1 if not reported by the kernel (< 5.3)
+}
+
+// NewUnixRequest returns the netlink message that asks the kernel to dump
+// every unix domain socket, including its name and peer.
+func NewUnixRequest(seq uint32) []byte {
+	size := sizeofNlMsghdr + sizeofUnixDiagReq
+	b := newHeader(size, seq)
+
+	// struct unix_diag_req
+	b = append(b, afUnix, 0, 0, 0)
+	b = appendUint32(b, 0xffffffff) // states
+	b = appendUint32(b, 0)          // inode
+	b = appendUint32(b, udiagShowName|udiagShowPeer|udiagShowUid)
+	return append(b, make([]byte, 8)...) // cookie
+}
+
+// ParseUnixMessages decodes a buffer of netlink messages received in
+// response to a request produced by NewUnixRequest. "done" is true when
+// the end of the dump has been reached.
+func ParseUnixMessages(b []byte) (set []UnixSocket, done bool, err error) {
+	set = []UnixSocket{}
+	done, err = walkMessages(b, func(data []byte) error {
+		s, err := ParseUnixSocket(data)
+		if err != nil {
+			return err
+		}
+		set = append(set, *s)
+		return nil
+	})
+	return set, done, err
+}
+
+// ParseUnixSocket decodes a struct unix_diag_msg, followed by its
+// attributes.
+func ParseUnixSocket(b []byte) (*UnixSocket, error) {
+	if len(b) < sizeofUnixDiagMsg {
+		return nil, fmt.Errorf("truncated unix_diag_msg: %d bytes", len(b))
+	}
+	if b[0] != afUnix {
+		return nil, fmt.Errorf("unexpected address family %d", b[0])
+	}
+	s := &UnixSocket{
+		Type:  unixTypes[b[1]],
+		State: procfs.State(b[2]),
+		Inode: uint64(internal.NativeEndian.Uint32(b[4:8])),
+		Uid:   -1,
+	}
+
+	attrs := b[sizeofUnixDiagMsg:]
+	for len(attrs) >= sizeofRtAttr {
+		l := int(internal.NativeEndian.Uint16(attrs[0:2]))
+		typ := internal.NativeEndian.Uint16(attrs[2:4])
+		if l < sizeofRtAttr || l > len(attrs) {
+			return nil, fmt.Errorf("invalid attribute length %d", l)
+		}
+		data := attrs[sizeofRtAttr:l]
+		switch typ {
+		case unixDiagName:
+			s.Path = parseUnixName(data)
+		case unixDiagPeer:
+			if len(data) >= 4 {
+				s.Peer = uint64(internal.NativeEndian.Uint32(data[0:4]))
+			}
+		case unixDiagUid:
+			if len(data) >= 4 {
+				s.Uid = int(internal.NativeEndian.Uint32(data[0:4]))
+			}
+		}
+		attrs = attrs[align(l):]
+	}
+	return s, nil
+}
+
+// parseUnixName decodes a sun_path, which is prefixed with a NUL byte for
+// abstract sockets. They are represented with a leading "@", as ss does.
+func parseUnixName(b []byte) string {
+	if len(b) > 0 && b[0] == 0 {
+		return "@" + string(b[1:])
+	}
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
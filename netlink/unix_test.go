@@ -0,0 +1,78 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package netlink
+
+import (
+	"testing"
+
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/procfs"
+)
+
+func TestNewUnixRequest(t *testing.T) {
+	t.Parallel()
+
+	req := NewUnixRequest(3)
+	assert(t, sizeofNlMsghdr+sizeofUnixDiagReq, len(req))
+	assert(t, uint32(len(req)), internal.NativeEndian.Uint32(req[0:4]))
+	assert(t, uint8(afUnix), req[16])
+	assert(t, uint32(udiagShowName|udiagShowPeer|udiagShowUid), internal.NativeEndian.Uint32(req[28:32]))
+}
+
+func TestParseUnixMessages(t *testing.T) {
+	t.Parallel()
+
+	var b []byte
+	b = append(b, message(sockDiagByFamily, unixMsg(procfs.Listen, 3765, 0, "/run/docker.sock\x00"))...)
+	b = append(b, message(sockDiagByFamily, unixMsg(procfs.Established, 904, 905, ""))...)
+	b = append(b, message(sockDiagByFamily, unixMsg(procfs.Close, 21391, 0, "\x00/tmp/.X11-unix/X0"))...)
+	b = append(b, message(nlmsgDone, make([]byte, 4))...)
+
+	set, done, err := ParseUnixMessages(b)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, true, done)
+	assert(t, 3, len(set))
+
+	assert(t, UnixSocket{Path: "/run/docker.sock", Type: "stream", State: procfs.Listen, Inode: 3765, Uid: 1000}, set[0])
+	assert(t, UnixSocket{Type: "stream", State: procfs.Established, Inode: 904, Peer: 905, Uid: 1000}, set[1])
+	assert(t, "@/tmp/.X11-unix/X0", set[2].Path)
+}
+
+func unixMsg(state procfs.State, inode, peer uint32, name string) []byte {
+	b := []byte{afUnix, 1, uint8(state), 0}
+	b = appendUint32(b, inode)
+	b = append(b, make([]byte, 8)...)
+	if name != "" {
+		b = appendUint16(b, uint16(sizeofRtAttr+len(name)))
+		b = appendUint16(b, unixDiagName)
+		b = append(b, name...)
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+	}
+	// UNIX_DIAG_UID, spelled out so that the constant is tested too.
+	b = appendUint16(b, sizeofRtAttr+4)
+	b = appendUint16(b, 7)
+	b = appendUint32(b, 1000)
+	if peer != 0 {
+		b = appendUint16(b, sizeofRtAttr+4)
+		b = appendUint16(b, unixDiagPeer)
+		b = appendUint32(b, peer)
+	}
+	return b
+}
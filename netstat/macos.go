@@ -0,0 +1,203 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package netstat
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/tool"
+)
+
+// Socket is a single line of the output of macOS' netstat, which differs
+// from the windows one in the address notation and in its columns.
+type Socket struct {
+	Raw     string
+	Proto   string // e.g. tcp4, tcp6, tcp46, udp4
+	Network string // tcp, udp
+	IPv6    bool   // true for tcp46 and udp46 sockets too
+	RecvQ   int
+	SendQ   int
+	SrcAddr net.Addr
+	DstAddr net.Addr
+	State   string // tcp only
+	Process string // printed from macOS 14 only, empty otherwise
+	Pid     int    // 0 if unknown
+}
+
+// RunDarwin executes ``netstat -anvW -p tcp'' and ``netstat -anvW -p udp'',
+// configured with "opts", and parses their output with
+// ``ParseDarwinOutput''. The -W option prevents long IPv6 addresses from
+// being truncated. Execution errors are of type *tool.Error.
+func RunDarwin(ctx context.Context, opts tool.Options) ([]Socket, error) {
+	set := []Socket{}
+	for _, proto := range []string{"tcp", "udp"} {
+		out, err := tool.Run(ctx, opts, "netstat", "-anvW", "-p", proto)
+		if err != nil {
+			return []Socket{}, fmt.Errorf("unable to run netstat: %w", err)
+		}
+		ss, err := ParseDarwinOutput(bytes.NewBuffer(out))
+		if err != nil {
+			return []Socket{}, err
+		}
+		set = append(set, ss...)
+	}
+	return set, nil
+}
+
+// ParseDarwinOutput expects "r" to contain the output of a macOS
+// ``netstat -anv'' call. Each line that ``ParseDarwinSocket'' is able to
+// parse is appended to the final output.
+// Returns an error only if reading from "r" produces an error
+// different from ``io.EOF''.
+func ParseDarwinOutput(r io.Reader) ([]Socket, error) {
+	set := []Socket{}
+	err := internal.ScanLines(r, func(line string) error {
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		s, err := ParseDarwinSocket(line)
+		if err != nil {
+			log.Printf("skipping netstat socket \"%s\": %v", line, err)
+			return nil
+		}
+		set = append(set, *s)
+		return nil
+	})
+	return set, err
+}
+
+// ParseDarwinSocket parses a single line of macOS' ``netstat -anv''. The
+// owner is printed as "process:pid" after the buffer sizes from macOS 14,
+// and as a bare pid column before, which is the third column following
+// the state.
+//
+// "line" examples:
+// "tcp4       0      0  192.168.1.5.54321      17.57.146.20.5223      ESTABLISHED 131072 131768    412      0 0x0102 0x00000020"
+// "tcp6       0      0  *.5000                 *.*                    LISTEN        0        0  131072  131072  ControlCenter:569  00100 00000006"
+func ParseDarwinSocket(line string) (*Socket, error) {
+	chunks, err := internal.ChunkLine(line, " ", 5)
+	if err != nil {
+		return nil, err
+	}
+	s := &Socket{
+		Raw:   line,
+		Proto: chunks[0],
+	}
+	switch {
+	case strings.HasPrefix(s.Proto, "tcp"):
+		s.Network = "tcp"
+	case strings.HasPrefix(s.Proto, "udp"):
+		s.Network = "udp"
+	default:
+		return nil, fmt.Errorf("unsupported protocol %s", s.Proto)
+	}
+	s.IPv6 = strings.HasSuffix(s.Proto, "6")
+	if s.RecvQ, err = strconv.Atoi(chunks[1]); err != nil {
+		return nil, fmt.Errorf("error parsing Recv-Q: %w", err)
+	}
+	if s.SendQ, err = strconv.Atoi(chunks[2]); err != nil {
+		return nil, fmt.Errorf("error parsing Send-Q: %w", err)
+	}
+	if s.SrcAddr, err = ParseDarwinAddr(s.Network, chunks[3]); err != nil {
+		return nil, fmt.Errorf("error parsing local address: %w", err)
+	}
+	if s.DstAddr, err = ParseDarwinAddr(s.Network, chunks[4]); err != nil {
+		return nil, fmt.Errorf("error parsing foreign address: %w", err)
+	}
+	rest := chunks[5:]
+	if s.Network == "tcp" {
+		// udp sockets leave the state column empty.
+		if len(rest) == 0 {
+			return nil, fmt.Errorf("missing state")
+		}
+		s.State, rest = rest[0], rest[1:]
+	}
+	s.Process, s.Pid = parseDarwinOwner(rest)
+	return s, nil
+}
+
+// parseDarwinOwner looks for the owner in the columns that follow the
+// state. Process names may contain spaces, hence the "process:pid"
+// column extends back to the last numeric column preceding it.
+func parseDarwinOwner(rest []string) (string, int) {
+	for k, v := range rest {
+		i := strings.LastIndex(v, ":")
+		if i <= 0 || !isDigits(v[i+1:]) {
+			continue
+		}
+		pid, _ := strconv.Atoi(v[i+1:])
+		start := k
+		for start > 0 && !isDigits(rest[start-1]) {
+			start--
+		}
+		name := strings.Join(append(rest[start:k:k], v[:i]), " ")
+		return name, pid
+	}
+	if len(rest) > 2 && isDigits(rest[2]) {
+		pid, _ := strconv.Atoi(rest[2])
+		return "", pid
+	}
+	return "", 0
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseDarwinAddr parses an address as printed by macOS' netstat, where
+// the port follows the host after a dot, e.g. "192.168.1.5.54321",
+// "fe80::1%lo0.49152", "*.5000" or "*.*". Unspecified peers are returned
+// as empty addresses.
+func ParseDarwinAddr(network, s string) (net.Addr, error) {
+	i := strings.LastIndex(s, ".")
+	if i < 0 {
+		return nil, fmt.Errorf("missing port in address %s", s)
+	}
+	host, port := s[:i], s[i+1:]
+	if port == "*" {
+		return internal.NewAddr(network, ""), nil
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return nil, fmt.Errorf("invalid port in address %s", s)
+	}
+	if host == "*" {
+		return internal.NewAddr(network, "*:"+port), nil
+	}
+	ip := host
+	if j := strings.Index(host, "%"); j >= 0 {
+		ip = host[:j]
+	}
+	if net.ParseIP(ip) == nil {
+		return nil, fmt.Errorf("invalid host in address %s", s)
+	}
+	return internal.NewAddr(network, net.JoinHostPort(host, port)), nil
+}
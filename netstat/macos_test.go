@@ -0,0 +1,132 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package netstat
+
+import (
+	"bytes"
+	"testing"
+)
+
+const venturaExample = `Active Internet connections (including servers)
+Proto Recv-Q Send-Q  Local Address          Foreign Address        (state)     rhiwat shiwat    pid   epid  state    options           gencnt    flags   flags1 usscnt rtncnt fltrs
+tcp4       0      0  192.168.1.5.54321      17.57.146.20.5223      ESTABLISHED 131072 131768    412      0 0x0102 0x00000020 00000000000a4a31 00000080 04000900      1      0 000001
+tcp46      0      0  *.5000                 *.*                    LISTEN      131072 131072    569      0 0x0100 0x00000006 00000000000008a6 00000000 00000900      1      0 000001
+tcp6       0      0  fe80::1%lo0.49152      fe80::1%lo0.631        ESTABLISHED 407878 146988    301      0 0x0102 0x00000000 00000000000b1c2d 00000080 00000900      1      0 000001
+udp4       0      0  *.5353                 *.*                                786896   9216    389      0 0x0100 0x00000000 0000000000000679 00000000 00000800      1      0 000001
+tcp4       0      0  192.168.1.5.443        10.0.0.7.50211         SYN_RCVD    131072 131768    412      0 0x0102 0x00000020 00000000000a4a32 00000080 04000900      1      0 000001
+`
+
+func TestParseDarwinOutput(t *testing.T) {
+	t.Parallel()
+
+	set, err := ParseDarwinOutput(bytes.NewBufferString(venturaExample))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 5 {
+		t.Fatalf("Unexpected set length: wanted 5, found %d: %v", len(set), set)
+	}
+
+	s := set[0]
+	assert(t, "tcp4", s.Proto)
+	assert(t, "tcp", s.Network)
+	assert(t, false, s.IPv6)
+	assert(t, "192.168.1.5:54321", s.SrcAddr.String())
+	assert(t, "17.57.146.20:5223", s.DstAddr.String())
+	assert(t, "ESTABLISHED", s.State)
+	assert(t, 412, s.Pid)
+	assert(t, "", s.Process)
+
+	s = set[1]
+	assert(t, true, s.IPv6)
+	assert(t, "*:5000", s.SrcAddr.String())
+	assert(t, "", s.DstAddr.String())
+	assert(t, "LISTEN", s.State)
+	assert(t, 569, s.Pid)
+
+	s = set[2]
+	assert(t, "[fe80::1%lo0]:49152", s.SrcAddr.String())
+	assert(t, "[fe80::1%lo0]:631", s.DstAddr.String())
+
+	s = set[3]
+	assert(t, "udp", s.Network)
+	assert(t, "", s.State)
+	assert(t, 389, s.Pid)
+
+	s = set[4]
+	assert(t, "SYN_RCVD", s.State)
+	assert(t, "10.0.0.7:50211", s.DstAddr.String())
+}
+
+const sonomaExample = `Active Internet connections (including servers)
+Proto Recv-Q Send-Q  Local Address          Foreign Address        (state)      rxbytes      txbytes  rhiwat  shiwat    process:pid    state      options   gencnt    flags   flags1 usecnt rtncnt fltrs
+tcp4       0      0  127.0.0.1.49153        *.*                    LISTEN             0            0  131072  131072     rapportd:412    00100 00000006 0000000000000c1f 00000000 00000900      1      0 000001
+tcp6       0      0  ::1.8080               ::1.50123              ESTABLISHED     1024         2048  407878  146988  Code Helper:901    00102 00000000 0000000000000c20 00000080 00000900      1      0 000001
+udp46      0      0  *.*                    *.*                                   0            0  786896    9216  mDNSResponder:389 00100 00000000 0000000000000679 00000000 00000800      1      0 000001
+`
+
+func TestParseDarwinOutput_Process(t *testing.T) {
+	t.Parallel()
+
+	set, err := ParseDarwinOutput(bytes.NewBufferString(sonomaExample))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 3 {
+		t.Fatalf("Unexpected set length: wanted 3, found %d: %v", len(set), set)
+	}
+	assert(t, "rapportd", set[0].Process)
+	assert(t, 412, set[0].Pid)
+	assert(t, "127.0.0.1:49153", set[0].SrcAddr.String())
+
+	assert(t, "Code Helper", set[1].Process)
+	assert(t, 901, set[1].Pid)
+	assert(t, "[::1]:8080", set[1].SrcAddr.String())
+	assert(t, "[::1]:50123", set[1].DstAddr.String())
+
+	assert(t, "mDNSResponder", set[2].Process)
+	assert(t, "", set[2].SrcAddr.String())
+}
+
+func TestParseDarwinAddr(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		in  string
+		out string
+	}{
+		{"127.0.0.1.631", "127.0.0.1:631"},
+		{"*.5353", "*:5353"},
+		{"*.*", ""},
+		{"::1.8080", "[::1]:8080"},
+		{"2001:db8::1.443", "[2001:db8::1]:443"},
+		{"fe80::1%lo0.49152", "[fe80::1%lo0]:49152"},
+	}
+	for i, v := range tt {
+		addr, err := ParseDarwinAddr("tcp", v.in)
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		assert(t, v.out, addr.String())
+		assert(t, "tcp", addr.Network())
+	}
+
+	for _, v := range []string{"127.0.0.1:631", "127.0.0.1.http", "fe80::aede:48ff:.5353"} {
+		if _, err := ParseDarwinAddr("tcp", v); err == nil {
+			t.Fatalf("expected error parsing \"%s\"", v)
+		}
+	}
+}
@@ -16,15 +16,16 @@ package netstat
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/jecoz/lsaddr/internal"
-	"gopkg.in/pipe.v2"
+	"github.com/jecoz/lsaddr/tool"
 )
 
 type ActiveConnection struct {
@@ -33,20 +34,26 @@ type ActiveConnection struct {
 	SrcAddr net.Addr
 	DstAddr net.Addr
 	State   string
-	Pid     int
+	Pid     int // 0 if unknown, i.e. when the -o option is not used
+
+	// Only available with the -b option.
+	Executable string   // e.g. chrome.exe
+	Components []string // e.g. the service hosted by svchost.exe
+	Unowned    bool     // ownership information could not be obtained
 }
 
-func Run() ([]ActiveConnection, error) {
-	log.Printf("Executing: netstat -nao")
-	p := pipe.Exec("netstat", "-nao")
+// NoOwnership is printed by ``netstat -b'' in place of the owner of a
+// connection when it cannot be inspected.
+const NoOwnership = "Can not obtain ownership information"
 
-	acc := []ActiveConnection{}
-	out, err := pipe.OutputTimeout(p, time.Millisecond*100)
+// Run executes ``netstat -nao'', configured with "opts", and parses
+// its output. Execution errors are of type *tool.Error.
+func Run(ctx context.Context, opts tool.Options) ([]ActiveConnection, error) {
+	out, err := tool.Run(ctx, opts, "netstat", "-nao")
 	if err != nil {
-		return acc, fmt.Errorf("unable to run netstat: %w", err)
+		return []ActiveConnection{}, fmt.Errorf("unable to run netstat: %w", err)
 	}
-	buf := bytes.NewBuffer(out)
-	return ParseOutput(buf)
+	return ParseOutput(bytes.NewBuffer(out))
 }
 
 // ParseOutput expects "r" to contain the output of
@@ -69,17 +76,69 @@ func ParseOutput(r io.Reader) ([]ActiveConnection, error) {
 	return set, err
 }
 
+// RunOwners executes ``netstat -nabo'', configured with "opts", and
+// parses its output with ``ParseOwnersOutput''. The -b option requires
+// elevated privileges. Execution errors are of type *tool.Error.
+func RunOwners(ctx context.Context, opts tool.Options) ([]ActiveConnection, error) {
+	out, err := tool.Run(ctx, opts, "netstat", "-nabo")
+	if err != nil {
+		return []ActiveConnection{}, fmt.Errorf("unable to run netstat: %w", err)
+	}
+	return ParseOwnersOutput(bytes.NewBuffer(out))
+}
+
+// ParseOwnersOutput expects "r" to contain the output of a
+// ``netstat -nab'' or ``netstat -nabo'' call, where each connection may
+// be followed by the components involved in it, e.g. a service name, and
+// by the owning executable between brackets, e.g. "[chrome.exe]". Those
+// lines are associated with the preceding connection, and appended to its
+// raw output.
+// Returns an error only if reading from "r" produces an error
+// different from ``io.EOF''.
+func ParseOwnersOutput(r io.Reader) ([]ActiveConnection, error) {
+	set := []ActiveConnection{}
+	var last *ActiveConnection
+	err := internal.ScanLines(r, func(line string) error {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			return nil
+		}
+		if ac, err := ParseActiveConnection(line); err == nil {
+			set = append(set, *ac)
+			last = &set[len(set)-1]
+			return nil
+		}
+		if last == nil {
+			log.Printf("skipping netstat line \"%s\"", line)
+			return nil
+		}
+		last.Raw += "\n" + line
+		switch {
+		case strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"):
+			last.Executable = strings.Trim(trimmed, "[]")
+		case trimmed == NoOwnership:
+			last.Unowned = true
+		default:
+			last.Components = append(last.Components, trimmed)
+		}
+		return nil
+	})
+	return set, err
+}
+
 // ParseActiveConnection expectes "line" to be a single line output from
 // ``netstat -nao'' call. The line is unmarshaled into an ``ActiveConnection''
-// only if is splittable by " " into a slice of at least 4 items. "line" should
+// only if is splittable by " " into a slice of at least 3 items. "line" should
 // not end with a "\n" delimitator, otherwise it will end up in the last
-// unmarshaled item.
+// unmarshaled item. The pid column is optional, as it is printed only
+// with the -o option.
 //
 // "line" examples:
 // "  TCP    0.0.0.0:5357           0.0.0.0:0              LISTENING       4"
 // "  UDP    [::1]:62261            *:*                                    1036"
+// "  TCP    0.0.0.0:135            0.0.0.0:0              LISTENING"
 func ParseActiveConnection(line string) (*ActiveConnection, error) {
-	chunks, err := internal.ChunkLine(line, " ", 4)
+	chunks, err := internal.ChunkLine(line, " ", 3)
 	if err != nil {
 		return nil, err
 	}
@@ -94,6 +153,13 @@ func ParseActiveConnection(line string) (*ActiveConnection, error) {
 		// connection not usable.
 		return nil, fmt.Errorf("unable to parse addresses: %w", err)
 	}
+	// Unspecified peers, e.g. "*:*", are missing.
+	if src == nil {
+		src = internal.NewAddr(proto, "")
+	}
+	if dst == nil {
+		dst = internal.NewAddr(proto, "")
+	}
 
 	ac := &ActiveConnection{
 		Raw:     line,
@@ -101,17 +167,16 @@ func ParseActiveConnection(line string) (*ActiveConnection, error) {
 		SrcAddr: src,
 		DstAddr: dst,
 	}
-	hasState := len(chunks) > 4
-	pidIndex := 3
-	if hasState {
-		pidIndex = 4
-		ac.State = chunks[3]
+	// The pid, when present, is always the last column. Some localized
+	// versions print states made of more words, e.g. "IN ASCOLTO".
+	end := len(chunks)
+	if pid, err := strconv.Atoi(chunks[end-1]); err == nil && end > 3 {
+		ac.Pid = pid
+		end--
 	}
-	pid, err := strconv.Atoi(chunks[pidIndex])
-	if err != nil {
-		return nil, fmt.Errorf("error parsing pid: %w", err)
+	if end > 3 {
+		ac.State = strings.Join(chunks[3:end], " ")
 	}
-	ac.Pid = pid
 
 	return ac, nil
 }
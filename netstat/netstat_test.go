@@ -62,6 +62,91 @@ func TestParseActiveConnection(t *testing.T) {
 	assert(t, 748, ac.Pid)
 }
 
+func TestParseActiveConnection_Localized(t *testing.T) {
+	t.Parallel()
+	line := "  TCP    0.0.0.0:135            0.0.0.0:0              IN ASCOLTO      748"
+	ac, err := ParseActiveConnection(line)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert(t, "IN ASCOLTO", ac.State)
+	assert(t, 748, ac.Pid)
+
+	line = "  UDP    [::1]:62261            *:*                                    1036"
+	if ac, err = ParseActiveConnection(line); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert(t, "", ac.State)
+	assert(t, 1036, ac.Pid)
+}
+
+const netstatOwnersExample = `
+Active Connections
+
+  Proto  Local Address          Foreign Address        State
+  TCP    0.0.0.0:135            0.0.0.0:0              LISTENING
+  RpcSs
+ [svchost.exe]
+  TCP    0.0.0.0:445            0.0.0.0:0              LISTENING
+ Can not obtain ownership information
+  TCP    192.168.1.5:50123      142.250.180.78:443     ESTABLISHED
+ [chrome.exe]
+  TCP    192.168.1.5:50200      20.42.65.92:443        ESTABLISHED
+  WpnService
+  Dnscache
+ [svchost.exe]
+  UDP    0.0.0.0:500            *:*
+  IKEEXT
+ [svchost.exe]
+`
+
+func TestParseOwnersOutput(t *testing.T) {
+	t.Parallel()
+
+	set, err := ParseOwnersOutput(bytes.NewBufferString(netstatOwnersExample))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 5 {
+		t.Fatalf("Unexpected set length: wanted 5, found %d: %v", len(set), set)
+	}
+	assert(t, "svchost.exe", set[0].Executable)
+	assert(t, []string{"RpcSs"}, set[0].Components)
+	assert(t, "LISTENING", set[0].State)
+	assert(t, 0, set[0].Pid)
+	assert(t, "  TCP    0.0.0.0:135            0.0.0.0:0              LISTENING\n  RpcSs\n [svchost.exe]", set[0].Raw)
+
+	assert(t, "", set[1].Executable)
+	assert(t, true, set[1].Unowned)
+	assert(t, 0, len(set[1].Components))
+
+	assert(t, "chrome.exe", set[2].Executable)
+	assert(t, false, set[2].Unowned)
+	assert(t, []string{"WpnService", "Dnscache"}, set[3].Components)
+
+	assert(t, "UDP", set[4].Proto)
+	assert(t, "", set[4].State)
+	assert(t, "svchost.exe", set[4].Executable)
+}
+
+func TestParseOwnersOutput_Pid(t *testing.T) {
+	t.Parallel()
+
+	set, err := ParseOwnersOutput(bytes.NewBufferString(netstatExample))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 4 {
+		t.Fatalf("Unexpected set length: wanted 4, found %d: %v", len(set), set)
+	}
+	assert(t, 748, set[0].Pid)
+	assert(t, "svchost.exe", set[0].Executable)
+	assert(t, true, set[1].Unowned)
+	assert(t, "svchost.exe", set[2].Executable)
+	assert(t, 1036, set[3].Pid)
+	assert(t, "", set[3].Executable)
+}
+
 func assert(t *testing.T, exp, x interface{}) {
 	if !reflect.DeepEqual(exp, x) {
 		t.Fatalf("Assert failed: expected %v, found %v", exp, x)
@@ -0,0 +1,111 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jecoz/lsaddr/tool"
+)
+
+// DefaultSource is the name of the fetcher used by FetchAll, which picks
+// the best backend available on the current platform.
+const DefaultSource = "auto"
+
+// Options configures how open network files are collected. Backends
+// that do not execute external tools ignore the execution options.
+type Options struct {
+	tool.Options
+	Unix bool // include unix domain sockets
+
+	// Sport and Dport are hints for backends able to filter sockets by
+	// their ports while collecting them, i.e. netlink. Callers must still
+	// filter the results, as other backends ignore them. Nil means any.
+	Sport *PortRange
+	Dport *PortRange
+
+	// States is a hint too, listing the states of the sockets wanted.
+	// Empty means every state.
+	States []State
+}
+
+// Fetcher is implemented by every source of open network files.
+type Fetcher interface {
+	Fetch(ctx context.Context, opts Options) ([]ONF, error)
+}
+
+// FetcherFunc is an adapter that allows the use of ordinary functions as
+// Fetchers.
+type FetcherFunc func(ctx context.Context, opts Options) ([]ONF, error)
+
+// Fetch calls f(ctx, opts).
+func (f FetcherFunc) Fetch(ctx context.Context, opts Options) ([]ONF, error) {
+	return f(ctx, opts)
+}
+
+var (
+	fetchersMu sync.RWMutex
+	fetchers   = make(map[string]Fetcher)
+)
+
+func init() {
+	// fetchAll implementations may be found inside the
+	// runtime_*.go files.
+	Register(DefaultSource, FetcherFunc(fetchAll))
+}
+
+// Register makes a fetcher available by the provided name, replacing any
+// fetcher previously registered with the same name. This allows tests to
+// swap a real backend with a fake one. Register panics if "f" is nil.
+func Register(name string, f Fetcher) {
+	if f == nil {
+		panic("onf: Register fetcher is nil")
+	}
+	fetchersMu.Lock()
+	defer fetchersMu.Unlock()
+	fetchers[strings.ToLower(name)] = f
+}
+
+// Lookup returns the fetcher registered with "name".
+func Lookup(name string) (Fetcher, error) {
+	fetchersMu.RLock()
+	defer fetchersMu.RUnlock()
+	f, ok := fetchers[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown source %s (available: %s)", name, strings.Join(sources(), ", "))
+	}
+	return f, nil
+}
+
+// Sources returns the sorted list of registered fetcher names.
+func Sources() []string {
+	fetchersMu.RLock()
+	defer fetchersMu.RUnlock()
+	return sources()
+}
+
+func sources() []string {
+	names := make([]string, 0, len(fetchers))
+	for k := range fetchers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,62 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jecoz/lsaddr/onf"
+)
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	fake := []onf.ONF{{Cmd: "foo", Pid: 101, Fd: 3}}
+	onf.Register("Fake", onf.FetcherFunc(func(ctx context.Context, opts onf.Options) ([]onf.ONF, error) {
+		return fake, nil
+	}))
+
+	f, err := onf.Lookup("fake")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	set, err := f.Fetch(context.Background(), onf.Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 1 || set[0].Cmd != "foo" {
+		t.Fatalf("Unexpected set: %v", set)
+	}
+
+	found := false
+	for _, v := range onf.Sources() {
+		if v == "fake" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("fake not found in sources: %v", onf.Sources())
+	}
+	for _, v := range []string{onf.DefaultSource, "lsof", "netstat", "netstat-b", "netstat-macos", "powershell", "procfs", "netlink", "ss", "sockstat", "file"} {
+		if _, err := onf.Lookup(v); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if _, err := onf.Lookup("missing"); err == nil {
+		t.Fatalf("Expected error looking up unregistered source")
+	}
+}
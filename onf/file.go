@@ -0,0 +1,116 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jecoz/lsaddr/lsof"
+	"github.com/jecoz/lsaddr/netstat"
+	"github.com/jecoz/lsaddr/powershell"
+	"github.com/jecoz/lsaddr/procfs"
+	"github.com/jecoz/lsaddr/sockstat"
+	"github.com/jecoz/lsaddr/ss"
+)
+
+func init() {
+	Register("file", File{})
+}
+
+// Input formats supported by File.
+const (
+	FormatLsof          = "lsof"          // lsof -i -n -P
+	FormatNetstat       = "netstat"       // netstat -nao
+	FormatNetstatOwners = "netstat-b"     // netstat -nab or -nabo
+	FormatProc          = "proc"          // cat /proc/net/{tcp,udp}[6]
+	FormatPowerShell    = "powershell"    // Get-NetTCPConnection | ConvertTo-Json
+	FormatSs            = "ss"            // ss -tuanpieO
+	FormatSockstat      = "sockstat"      // sockstat -46 -c -l
+	FormatNetstatMacOS  = "netstat-macos" // netstat -anv -p tcp
+)
+
+// File is a Fetcher that reads the open network files from a previously
+// captured output of one of the supported tools, instead of inspecting
+// the running system. This is useful to inspect snapshots taken on other
+// machines.
+type File struct {
+	Path   string // file to read from, standard input when empty or "-"
+	Format string // one of the Format* constants, FormatLsof when empty
+
+	// Decode, when set, is used instead of Format to read the open
+	// network files, e.g. from a snapshot encoded by lsaddr itself.
+	Decode func(io.Reader) ([]ONF, error)
+}
+
+// Fetch implements Fetcher. Options are ignored.
+func (f File) Fetch(ctx context.Context, opts Options) ([]ONF, error) {
+	var r io.Reader = os.Stdin
+	if f.Path != "" && f.Path != "-" {
+		file, err := os.Open(f.Path)
+		if err != nil {
+			return []ONF{}, fmt.Errorf("unable to open input: %w", err)
+		}
+		defer file.Close()
+		r = file
+	}
+	parse := func(r io.Reader) ([]ONF, error) { return Parse(r, f.Format) }
+	if f.Decode != nil {
+		parse = f.Decode
+	}
+	set, err := parse(r)
+	if err != nil {
+		return []ONF{}, fmt.Errorf("unable to parse input: %w", err)
+	}
+	return set, nil
+}
+
+// Parse reads the captured output of the tool identified by "format"
+// from "r". Sockets parsed from /proc/net tables are not attributed to
+// any process, as the inode information is not available offline.
+func Parse(r io.Reader, format string) ([]ONF, error) {
+	switch strings.ToLower(format) {
+	case "", FormatLsof:
+		set, err := lsof.ParseOutput(r)
+		return mapLsof(set), err
+	case FormatNetstat:
+		set, err := netstat.ParseOutput(r)
+		return mapNetstat(set), err
+	case FormatNetstatOwners:
+		set, err := netstat.ParseOwnersOutput(r)
+		return mapNetstat(set), err
+	case FormatPowerShell:
+		set, err := powershell.ParseOutput(r)
+		return mapPowerShell(set), err
+	case FormatSs:
+		set, err := ss.ParseOutput(r)
+		return mapSs(set), err
+	case FormatNetstatMacOS:
+		set, err := netstat.ParseDarwinOutput(r)
+		return mapNetstatDarwin(set), err
+	case FormatSockstat:
+		set, err := sockstat.ParseOutput(r)
+		return mapSockstat(set), err
+	case FormatProc:
+		set, err := procfs.ParseDump(r)
+		return mapProcfs(set, nil), err
+	default:
+		return []ONF{}, fmt.Errorf("unrecognised input format %s", format)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jecoz/lsaddr/onf"
+)
+
+func TestParse_Lsof(t *testing.T) {
+	t.Parallel()
+
+	in := `COMMAND     PID            USER   FD   TYPE             DEVICE SIZE/OFF NODE NAME
+Dropbox     614 danielmorandini  247u  IPv4 0x25c5bf09a393d583      0t0  TCP 192.168.0.61:58282->162.125.18.133:443 (ESTABLISHED)
+postgres    676 danielmorandini   10u  IPv6 0x25c5bf0997ca88e3      0t0  UDP [::1]:60051->[::1]:60051
+`
+	set, err := onf.Parse(strings.NewReader(in), onf.FormatLsof)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 2 {
+		t.Fatalf("Unexpected set length: wanted 2, found %d: %v", len(set), set)
+	}
+
+	f := set[0]
+	assert(t, "Dropbox", f.Cmd)
+	assert(t, 614, f.Pid)
+	assert(t, 247, f.Fd)
+	assert(t, "danielmorandini", f.User)
+	assert(t, -1, f.Uid)
+	assert(t, onf.FamilyIPv4, f.Family)
+	assert(t, "tcp", f.Proto)
+	assert(t, onf.StateEstablished, f.State)
+
+	f = set[1]
+	assert(t, onf.FamilyIPv6, f.Family)
+	assert(t, "udp", f.Proto)
+	assert(t, onf.StateUnknown, f.State)
+}
+
+func TestParse_Netstat(t *testing.T) {
+	t.Parallel()
+
+	in := `
+  Proto  Local Address          Foreign Address        State           PID
+  TCP    0.0.0.0:135            0.0.0.0:0              LISTENING       748
+  TCP    [::1]:49670            [::1]:49671            ESTABLISHED     3320
+  UDP    [::1]:62261            *:*                                    1036
+`
+	set, err := onf.Parse(strings.NewReader(in), onf.FormatNetstat)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 3 {
+		t.Fatalf("Unexpected set length: wanted 3, found %d: %v", len(set), set)
+	}
+	assert(t, onf.StateListen, set[0].State)
+	assert(t, onf.FamilyIPv4, set[0].Family)
+	assert(t, "tcp", set[0].Proto)
+	assert(t, onf.StateEstablished, set[1].State)
+	assert(t, onf.FamilyIPv6, set[1].Family)
+	assert(t, "udp", set[2].Proto)
+	assert(t, -1, set[2].Fd)
+}
+
+func TestParse_PowerShell(t *testing.T) {
+	t.Parallel()
+
+	in := `{"tcp": [{"LocalAddress": "192.168.1.5", "LocalPort": 50123, "RemoteAddress": "142.250.180.78", "RemotePort": 443, "State": 5, "OwningProcess": 4120, "CreationTime": "\/Date(1571213456789)\/"}],
+"udp": [{"LocalAddress": "::", "LocalPort": 5353, "OwningProcess": 2212, "CreationTime": null}]}`
+	set, err := onf.Parse(strings.NewReader(in), onf.FormatPowerShell)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 2 {
+		t.Fatalf("Unexpected set length: wanted 2, found %d: %v", len(set), set)
+	}
+	assert(t, onf.StateEstablished, set[0].State)
+	assert(t, 4120, set[0].Pid)
+	assert(t, "tcp", set[0].Proto)
+	assert(t, int64(1571213456), set[0].CreatedAt.Unix())
+	assert(t, onf.FamilyIPv6, set[1].Family)
+	assert(t, onf.StateUnknown, set[1].State)
+	assert(t, false, set[1].CreatedAt.IsZero())
+}
+
+func TestParse_NetstatOwners(t *testing.T) {
+	t.Parallel()
+
+	in := `
+  Proto  Local Address          Foreign Address        State           PID
+  TCP    0.0.0.0:135            0.0.0.0:0              LISTENING       748
+  RpcSs
+ [svchost.exe]
+  TCP    0.0.0.0:445            0.0.0.0:0              LISTENING       4
+ Can not obtain ownership information
+`
+	set, err := onf.Parse(strings.NewReader(in), onf.FormatNetstatOwners)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 2 {
+		t.Fatalf("Unexpected set length: wanted 2, found %d: %v", len(set), set)
+	}
+	assert(t, "svchost.exe", set[0].Cmd)
+	assert(t, 748, set[0].Pid)
+	assert(t, "", set[1].Cmd)
+
+	set, err = onf.Filter(set, "RpcSs")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, 1, len(set))
+}
+
+func assert(t *testing.T, exp, x interface{}) {
+	if !reflect.DeepEqual(exp, x) {
+		t.Fatalf("Assert failed: expected %v, found %v", exp, x)
+	}
+}
+
+func TestParse_Ss(t *testing.T) {
+	t.Parallel()
+
+	in := `Netid State  Recv-Q Send-Q Local Address:Port Peer Address:Port Process
+tcp   LISTEN 0      511    0.0.0.0:80         0.0.0.0:*     users:(("nginx",pid=12,fd=6),("nginx",pid=13,fd=6)) ino:21445 sk:2 <->
+udp   UNCONN 0      0      [::1]:53           [::]:*        uid:101 ino:17364 sk:1 <->
+tcp   ESTAB  0      0      [fe80::1]%eth0:22  [fe80::2]%eth0:51234 ino:184033 sk:3 <->
+tcp   LISTEN 0      128    *:8080             *:*           ino:184040 sk:4 <->
+`
+	set, err := onf.Parse(strings.NewReader(in), onf.FormatSs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 5 {
+		t.Fatalf("Unexpected set length: wanted 5, found %d: %v", len(set), set)
+	}
+	assert(t, "nginx", set[0].Cmd)
+	assert(t, 12, set[0].Pid)
+	assert(t, 13, set[1].Pid)
+	assert(t, 6, set[1].Fd)
+	assert(t, onf.StateListen, set[0].State)
+	assert(t, onf.FamilyIPv4, set[0].Family)
+	assert(t, uint64(21445), set[0].Inode)
+	assert(t, 0, set[0].Uid)
+
+	f := set[2]
+	assert(t, "udp", f.Proto)
+	assert(t, onf.FamilyIPv6, f.Family)
+	assert(t, onf.StateUnknown, f.State)
+	assert(t, 0, f.Pid)
+	assert(t, -1, f.Fd)
+	assert(t, 101, f.Uid)
+	// Snapshots may come from other hosts: uids are not resolved locally.
+	assert(t, "", f.User)
+
+	// Scoped and wildcard addresses.
+	assert(t, "[fe80::1%eth0]:22", set[3].Src.String())
+	assert(t, onf.FamilyIPv6, set[3].Family)
+	assert(t, "*:8080", set[4].Src.String())
+	assert(t, onf.FamilyIPv6, set[4].Family)
+}
+
+func TestParse_Sockstat(t *testing.T) {
+	t.Parallel()
+
+	in := `USER     COMMAND    PID   FD PROTO  LOCAL ADDRESS         FOREIGN ADDRESS
+root     sshd       812   4  tcp46  *:22                  *:*
+www      nginx      900   6  tcp4   192.168.1.10:80       10.0.0.5:51234
+root     syslogd    600   7  udp4   127.0.0.1:514         *:*
+`
+	set, err := onf.Parse(strings.NewReader(in), onf.FormatSockstat)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 3 {
+		t.Fatalf("Unexpected set length: wanted 3, found %d: %v", len(set), set)
+	}
+
+	f := set[0]
+	assert(t, "sshd", f.Cmd)
+	assert(t, 812, f.Pid)
+	assert(t, 4, f.Fd)
+	assert(t, "root", f.User)
+	assert(t, -1, f.Uid)
+	assert(t, onf.FamilyIPv6, f.Family)
+	assert(t, onf.StateListen, f.State)
+
+	assert(t, onf.FamilyIPv4, set[1].Family)
+	assert(t, onf.StateUnknown, set[1].State)
+	assert(t, "udp", set[2].Proto)
+	assert(t, onf.StateUnknown, set[2].State)
+}
+
+func TestParse_NetstatMacOS(t *testing.T) {
+	t.Parallel()
+
+	in := `Active Internet connections (including servers)
+Proto Recv-Q Send-Q  Local Address          Foreign Address        (state)      rxbytes      txbytes  rhiwat  shiwat    process:pid    state      options   gencnt    flags   flags1 usecnt rtncnt fltrs
+tcp46      0      0  *.5000                 *.*                    LISTEN             0            0  131072  131072 ControlCenter:569    00100 00000006 0000000000000c1f 00000000 00000900      1      0 000001
+tcp4       0      0  192.168.1.5.54321      17.57.146.20.5223      ESTABLISHED     1024         2048  131072  131768         apsd:412    00102 00000020 00000000000a4a31 00000080 04000900      1      0 000001
+tcp4       0      0  192.168.1.5.443        10.0.0.7.50211         SYN_RCVD           0            0  131072  131768        nginx:900    00102 00000020 00000000000a4a32 00000080 04000900      1      0 000001
+`
+	set, err := onf.Parse(strings.NewReader(in), onf.FormatNetstatMacOS)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 3 {
+		t.Fatalf("Unexpected set length: wanted 3, found %d: %v", len(set), set)
+	}
+
+	f := set[0]
+	assert(t, "ControlCenter", f.Cmd)
+	assert(t, 569, f.Pid)
+	assert(t, -1, f.Fd)
+	assert(t, onf.FamilyIPv6, f.Family)
+	assert(t, "tcp", f.Proto)
+	assert(t, onf.StateListen, f.State)
+	assert(t, "*:5000", f.Src.String())
+
+	f = set[1]
+	assert(t, onf.FamilyIPv4, f.Family)
+	assert(t, onf.StateEstablished, f.State)
+	assert(t, "17.57.146.20:5223", f.Dst.String())
+
+	assert(t, onf.StateSynRecv, set[2].State)
+}
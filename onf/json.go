@@ -0,0 +1,177 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jecoz/lsaddr/internal"
+)
+
+// jsonONF is the JSON representation of an ONF. Fields are never removed
+// or renamed, as scripts depend on them.
+type jsonONF struct {
+	Raw       string    `json:"raw"`
+	Cmd       string    `json:"cmd"`
+	Pid       int       `json:"pid"`
+	Fd        int       `json:"fd"`
+	User      string    `json:"user"`
+	Uid       int       `json:"uid"`
+	Family    Family    `json:"family"`
+	Proto     string    `json:"proto"`
+	State     string    `json:"state"`
+	Inode     uint64    `json:"inode"`
+	Src       *jsonAddr `json:"src"`
+	Dst       *jsonAddr `json:"dst"`
+	PeerPid   int       `json:"peer_pid"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// jsonAddr is the JSON representation of an address. Unix domain socket
+// addresses have a path instead of an ip and a port. The ip is "*" for
+// sockets bound to every interface, and the port is missing for sockets
+// not bound to any, e.g. lsof's "*:*".
+type jsonAddr struct {
+	Network string `json:"network"`
+	IP      string `json:"ip,omitempty"`
+	Zone    string `json:"zone,omitempty"`
+	Port    *int   `json:"port,omitempty"`
+	Path    string `json:"path,omitempty"`
+}
+
+// MarshalJSON encodes "f" as a JSON object, where addresses are objects
+// holding their ip, port and network. Missing addresses are encoded as
+// null.
+func (f ONF) MarshalJSON() ([]byte, error) {
+	src, err := marshalAddr(f.Src)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode source address: %w", err)
+	}
+	dst, err := marshalAddr(f.Dst)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode destination address: %w", err)
+	}
+	// Raw outputs are full of "->", which would be escaped otherwise.
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	err = enc.Encode(jsonONF{
+		Raw:       f.Raw,
+		Cmd:       f.Cmd,
+		Pid:       f.Pid,
+		Fd:        f.Fd,
+		User:      f.User,
+		Uid:       f.Uid,
+		Family:    f.Family,
+		Proto:     f.Proto,
+		State:     f.State.String(),
+		Inode:     f.Inode,
+		Src:       src,
+		Dst:       dst,
+		PeerPid:   f.PeerPid,
+		CreatedAt: f.CreatedAt,
+	})
+	return bytes.TrimRight(buf.Bytes(), "\n"), err
+}
+
+// UnmarshalJSON decodes an ONF encoded by MarshalJSON. Missing addresses
+// are decoded as empty addresses of the protocol of "f", as the backends
+// build them.
+func (f *ONF) UnmarshalJSON(b []byte) error {
+	var v jsonONF
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	src, err := unmarshalAddr(v.Src, v.Proto)
+	if err != nil {
+		return fmt.Errorf("unable to decode source address: %w", err)
+	}
+	dst, err := unmarshalAddr(v.Dst, v.Proto)
+	if err != nil {
+		return fmt.Errorf("unable to decode destination address: %w", err)
+	}
+	*f = ONF{
+		Raw:       v.Raw,
+		Cmd:       v.Cmd,
+		Pid:       v.Pid,
+		Fd:        v.Fd,
+		User:      v.User,
+		Uid:       v.Uid,
+		Family:    v.Family,
+		Proto:     v.Proto,
+		State:     ParseState(v.State),
+		Inode:     v.Inode,
+		Src:       src,
+		Dst:       dst,
+		PeerPid:   v.PeerPid,
+		CreatedAt: v.CreatedAt,
+	}
+	return nil
+}
+
+func marshalAddr(addr net.Addr) (*jsonAddr, error) {
+	if addr == nil || addr.String() == "" {
+		return nil, nil
+	}
+	if addr.Network() == "unix" {
+		return &jsonAddr{Network: "unix", Path: addr.String()}, nil
+	}
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil, err
+	}
+	a := &jsonAddr{Network: addr.Network(), IP: host}
+	if port != "*" {
+		n, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port in address %v", addr)
+		}
+		a.Port = &n
+	}
+	if i := strings.Index(host, "%"); i >= 0 {
+		a.IP, a.Zone = host[:i], host[i+1:]
+	}
+	return a, nil
+}
+
+func unmarshalAddr(a *jsonAddr, proto string) (net.Addr, error) {
+	if a == nil {
+		return internal.NewAddr(proto, ""), nil
+	}
+	if a.Network == "unix" {
+		return NewUnixAddr(a.Path), nil
+	}
+	if a.IP == "" {
+		return nil, fmt.Errorf("missing ip")
+	}
+	host, port := a.IP, "*"
+	if a.Zone != "" {
+		host += "%" + a.Zone
+	}
+	if a.Port != nil {
+		if *a.Port < 0 || *a.Port > 0xffff {
+			return nil, fmt.Errorf("invalid port %d", *a.Port)
+		}
+		port = strconv.Itoa(*a.Port)
+	}
+	return internal.NewAddr(a.Network, net.JoinHostPort(host, port)), nil
+}
@@ -0,0 +1,108 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/onf"
+)
+
+func TestONF_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	f := onf.ONF{
+		Raw:       "curl 1549",
+		Cmd:       "curl",
+		Pid:       1549,
+		Fd:        18,
+		User:      "root",
+		Uid:       0,
+		Family:    onf.FamilyIPv6,
+		Proto:     "tcp",
+		State:     onf.StateEstablished,
+		Inode:     24000,
+		Src:       internal.NewAddr("tcp", "[fe80::1%eth0]:59904"),
+		Dst:       internal.NewAddr("tcp", "[2001:db8::1]:443"),
+		CreatedAt: time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC),
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	exp := `{"raw":"curl 1549","cmd":"curl","pid":1549,"fd":18,"user":"root","uid":0,"family":"ipv6","proto":"tcp","state":"ESTABLISHED","inode":24000,` +
+		`"src":{"network":"tcp","ip":"fe80::1","zone":"eth0","port":59904},"dst":{"network":"tcp","ip":"2001:db8::1","port":443},"peer_pid":0,"created_at":"2019-09-01T12:00:00Z"}`
+	assert(t, exp, string(b))
+
+	var g onf.ONF
+	if err := json.Unmarshal(b, &g); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, f, g)
+}
+
+func TestONF_MarshalJSON_Addresses(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		proto string
+		src   string
+		dst   string
+		out   string
+	}{
+		{"udp", "*:5353", "", `"src":{"network":"udp","ip":"*","port":5353},"dst":null`},
+		{"udp", "*:*", "", `"src":{"network":"udp","ip":"*"},"dst":null`},
+		{"udp", "127.0.0.1:49152", "[::1]:*", `"dst":{"network":"udp","ip":"::1"}`},
+		{"tcp", "0.0.0.0:135", "0.0.0.0:0", `"src":{"network":"tcp","ip":"0.0.0.0","port":135},"dst":{"network":"tcp","ip":"0.0.0.0","port":0}`},
+		{"unix", "/run/docker.sock", "", `"src":{"network":"unix","path":"/run/docker.sock"},"dst":null`},
+	}
+	for i, v := range tt {
+		f := onf.ONF{Proto: v.proto, Src: internal.NewAddr(v.proto, v.src), Dst: internal.NewAddr(v.proto, v.dst)}
+		b, err := json.Marshal(f)
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		if !strings.Contains(string(b), v.out) {
+			t.Fatalf("%d: expected %s in %s", i, v.out, b)
+		}
+		var g onf.ONF
+		if err := json.Unmarshal(b, &g); err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		assert(t, f.Src, g.Src)
+		assert(t, f.Dst, g.Dst)
+	}
+}
+
+func TestONF_UnmarshalJSON_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tt := []string{
+		`{"proto":"tcp","src":{"network":"tcp","ip":"127.0.0.1","port":65536}}`,
+		`{"proto":"tcp","src":{"network":"tcp","port":80}}`,
+		`{"proto":"tcp","pid":"1"}`,
+	}
+	for i, v := range tt {
+		var f onf.ONF
+		if err := json.Unmarshal([]byte(v), &f); err == nil {
+			t.Fatalf("%d: expected error decoding %s", i, v)
+		}
+	}
+}
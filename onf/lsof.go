@@ -0,0 +1,79 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jecoz/lsaddr/lsof"
+)
+
+func init() {
+	Register("lsof", FetcherFunc(fetchLsof))
+}
+
+func fetchLsof(ctx context.Context, opts Options) ([]ONF, error) {
+	set, err := lsof.Run(ctx, opts.Options)
+	if err != nil {
+		return []ONF{}, err
+	}
+	if opts.Unix {
+		unix, err := lsof.RunUnix(ctx, opts.Options)
+		if err != nil {
+			return []ONF{}, err
+		}
+		set = append(set, unix...)
+	}
+	return mapLsof(set), nil
+}
+
+func mapLsof(set []lsof.OpenFile) []ONF {
+	mapped := make([]ONF, len(set))
+	for i, v := range set {
+		mapped[i] = ONF{
+			Raw:       v.Raw,
+			Cmd:       v.Command,
+			Pid:       v.Pid,
+			Fd:        parseLsofFd(v.Fd),
+			User:      v.User,
+			Uid:       v.Uid,
+			Family:    ParseFamily(v.Type),
+			Proto:     strings.ToLower(v.Protocol),
+			State:     ParseLsofState(v.State),
+			Src:       v.SrcAddr,
+			Dst:       v.DstAddr,
+			CreatedAt: time.Now(),
+		}
+	}
+	return mapped
+}
+
+// parseLsofFd extracts the file descriptor number from lsof's FD column,
+// which is followed by the access mode and lock characters, e.g. "128u".
+func parseLsofFd(s string) int {
+	n := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if n >= 0 {
+		s = s[:n]
+	}
+	fd, err := strconv.Atoi(s)
+	if err != nil {
+		return -1
+	}
+	return fd
+}
@@ -0,0 +1,132 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"context"
+	"time"
+
+	"github.com/jecoz/lsaddr/netlink"
+	"github.com/jecoz/lsaddr/procfs"
+)
+
+func init() {
+	Register("netlink", FetcherFunc(fetchNetlink))
+}
+
+// fetchNetlink dumps the sockets using sock_diag, and attributes them to
+// their processes as fetchProcfs does. The port and state hints of
+// "opts" are evaluated by the kernel. Unix domain sockets are included
+// when requested by "opts". The dumps are aborted when "ctx" is done or
+// the timeout of "opts" expires.
+func fetchNetlink(ctx context.Context, opts Options) ([]ONF, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	filter := netlink.Filter{
+		States: kernelStatesOf(opts.States),
+		Sport:  kernelPortRange(opts.Sport),
+		Dport:  kernelPortRange(opts.Dport),
+	}
+	set, err := netlink.Run(ctx, filter)
+	if err != nil {
+		return []ONF{}, err
+	}
+	var unix []netlink.UnixSocket
+	if opts.Unix {
+		if unix, err = netlink.RunUnix(ctx); err != nil {
+			return []ONF{}, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return []ONF{}, err
+	}
+	idx := scanInodes()
+	mapped := make([]ONF, 0, len(set)+len(unix))
+	for _, v := range set {
+		f := ONF{
+			User:      lookupUser(v.Uid),
+			Uid:       v.Uid,
+			Family:    ipFamily(v.IPv6),
+			Proto:     v.Network,
+			State:     socketState(v.Network, v.State),
+			Src:       v.SrcAddr,
+			Dst:       v.DstAddr,
+			CreatedAt: time.Now(),
+		}
+		mapped = attribute(mapped, f, idx, v.Inode)
+	}
+	return append(mapped, mapNetlinkUnix(unix, idx)...), nil
+}
+
+// mapNetlinkUnix maps unix domain sockets, using the path and owner of
+// their peers as destination.
+func mapNetlinkUnix(set []netlink.UnixSocket, idx procfs.InodeIndex) []ONF {
+	byInode := make(map[uint64]netlink.UnixSocket, len(set))
+	for _, v := range set {
+		byInode[v.Inode] = v
+	}
+	mapped := make([]ONF, 0, len(set))
+	for _, v := range set {
+		f := ONF{
+			User:      lookupUser(v.Uid),
+			Uid:       v.Uid,
+			Family:    FamilyUnix,
+			Proto:     "unix",
+			State:     stateFromKernel(v.State),
+			Src:       NewUnixAddr(v.Path),
+			Dst:       NewUnixAddr(byInode[v.Peer].Path),
+			CreatedAt: time.Now(),
+		}
+		if peers := idx.Lookup(v.Peer); len(peers) > 0 {
+			f.PeerPid = peers[0].Pid
+		}
+		mapped = attribute(mapped, f, idx, v.Inode)
+	}
+	return mapped
+}
+
+// kernelStatesOf returns the kernel states which are mapped to one of
+// "states", or nil when some of them, i.e. StateUnknown, cannot be
+// selected by the kernel. Udp sockets are filtered using the same
+// states, which is harmless as they are mapped to StateUnknown.
+func kernelStatesOf(states []State) []procfs.State {
+	acc := []procfs.State{}
+	for _, v := range states {
+		if v == StateUnknown {
+			return nil
+		}
+		for k, s := range kernelStates {
+			if s == v {
+				acc = append(acc, k)
+			}
+		}
+	}
+	if len(acc) == 0 {
+		return nil
+	}
+	return acc
+}
+
+// kernelPortRange converts "r" into the range evaluated by the kernel.
+func kernelPortRange(r *PortRange) *netlink.PortRange {
+	if r == nil {
+		return nil
+	}
+	return &netlink.PortRange{Lo: r.Lo, Hi: r.Hi}
+}
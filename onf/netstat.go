@@ -0,0 +1,162 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"context"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jecoz/lsaddr/netstat"
+	"github.com/jecoz/lsaddr/tasklist"
+	"github.com/jecoz/lsaddr/tool"
+)
+
+func init() {
+	Register("netstat", FetcherFunc(fetchNetstat))
+	Register("netstat-b", FetcherFunc(fetchNetstatOwners))
+	Register("netstat-macos", FetcherFunc(fetchNetstatDarwin))
+}
+
+// fetchNetstat runs netstat, and names the owners of the connections
+// using the process list printed by tasklist. Connections are returned
+// without command names when tasklist fails.
+func fetchNetstat(ctx context.Context, opts Options) ([]ONF, error) {
+	set, err := netstat.Run(ctx, opts.Options)
+	if err != nil {
+		return []ONF{}, err
+	}
+	return nameOwners(ctx, mapNetstat(set), opts), nil
+}
+
+// fetchNetstatOwners runs ``netstat -nabo'', which reports the executable
+// owning each connection. Connections whose owner is not reported are
+// named using tasklist, as fetchNetstat does.
+func fetchNetstatOwners(ctx context.Context, opts Options) ([]ONF, error) {
+	set, err := netstat.RunOwners(ctx, opts.Options)
+	if err != nil {
+		return []ONF{}, err
+	}
+	return nameOwners(ctx, mapNetstat(set), opts), nil
+}
+
+func nameOwners(ctx context.Context, set []ONF, opts Options) []ONF {
+	tasks, err := tasklist.Run(ctx, tool.Options{Timeout: opts.Timeout})
+	if err != nil {
+		log.Printf("unable to name connection owners: %v", err)
+		return set
+	}
+	return joinTasks(set, tasks)
+}
+
+// joinTasks fills the command names of "set" with the image names of
+// the matching "tasks".
+func joinTasks(set []ONF, tasks []tasklist.Task) []ONF {
+	images := make(map[int]string, len(tasks))
+	for _, v := range tasks {
+		images[v.Pid] = v.Image
+	}
+	for i, v := range set {
+		if v.Attributed() && v.Cmd == "" {
+			set[i].Cmd = images[v.Pid]
+		}
+	}
+	return set
+}
+
+func mapNetstat(set []netstat.ActiveConnection) []ONF {
+	mapped := make([]ONF, len(set))
+	for i, v := range set {
+		mapped[i] = ONF{
+			Raw:       v.Raw,
+			Cmd:       v.Executable,
+			Pid:       v.Pid,
+			Fd:        -1,
+			Uid:       -1,
+			Family:    familyOf(v.SrcAddr),
+			Proto:     strings.ToLower(v.Proto),
+			State:     ParseState(v.State),
+			Src:       v.SrcAddr,
+			Dst:       v.DstAddr,
+			CreatedAt: time.Now(),
+		}
+	}
+	return mapped
+}
+
+// fetchNetstatDarwin runs macOS' netstat, which reports the pid owning
+// each socket without requiring elevated privileges. Command names are
+// taken from the process table when netstat does not print them. Unix
+// domain sockets are not collected.
+func fetchNetstatDarwin(ctx context.Context, opts Options) ([]ONF, error) {
+	if opts.Unix {
+		log.Printf("unix domain sockets are not collected using netstat")
+	}
+	set, err := netstat.RunDarwin(ctx, opts.Options)
+	if err != nil {
+		return []ONF{}, err
+	}
+	return nameProcesses(ctx, mapNetstatDarwin(set), opts), nil
+}
+
+func nameProcesses(ctx context.Context, set []ONF, opts Options) []ONF {
+	unnamed := false
+	for _, v := range set {
+		unnamed = unnamed || (v.Attributed() && v.Cmd == "")
+	}
+	if !unnamed {
+		return set
+	}
+	t, err := fetchPs(ctx, opts)
+	if err != nil {
+		log.Printf("unable to name socket owners: %v", err)
+		return set
+	}
+	return joinProcesses(set, t)
+}
+
+// joinProcesses fills the command names of "set" with the base name of
+// the matching commands of "t".
+func joinProcesses(set []ONF, t ProcessTable) []ONF {
+	for i, v := range set {
+		if p, ok := t[v.Pid]; ok && v.Attributed() && v.Cmd == "" {
+			set[i].Cmd = path.Base(p.Cmd)
+		}
+	}
+	return set
+}
+
+func mapNetstatDarwin(set []netstat.Socket) []ONF {
+	mapped := make([]ONF, len(set))
+	for i, v := range set {
+		mapped[i] = ONF{
+			Raw:       v.Raw,
+			Cmd:       v.Process,
+			Pid:       v.Pid,
+			Fd:        -1,
+			Uid:       -1,
+			Family:    ipFamily(v.IPv6),
+			Proto:     v.Network,
+			State:     ParseState(v.State),
+			Src:       v.SrcAddr,
+			Dst:       v.DstAddr,
+			CreatedAt: time.Now(),
+		}
+	}
+	return mapped
+}
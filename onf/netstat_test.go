@@ -0,0 +1,83 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/jecoz/lsaddr/netstat"
+	"github.com/jecoz/lsaddr/tasklist"
+)
+
+func TestJoinTasks(t *testing.T) {
+	t.Parallel()
+
+	conns, err := netstat.ParseOutput(bytes.NewBufferString(`
+  TCP    0.0.0.0:135            0.0.0.0:0              LISTENING       748
+  TCP    192.168.1.5:50123      142.250.180.78:443     ESTABLISHED     4120
+  TCP    192.168.1.5:50124      142.250.180.78:443     TIME_WAIT       0
+  UDP    [::1]:62261            *:*                                    1036
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tasks, err := tasklist.ParseOutput(bytes.NewBufferString(`"System Idle Process","0","Services","0","8 K"
+"svchost.exe","748","Services","0","14,316 K"
+"chrome.exe","4120","Console","1","123,456 K"
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	set := joinTasks(mapNetstat(conns), tasks)
+	cmds := []string{}
+	for _, v := range set {
+		cmds = append(cmds, v.Cmd)
+	}
+	want := []string{"svchost.exe", "chrome.exe", "", ""}
+	if !reflect.DeepEqual(want, cmds) {
+		t.Fatalf("Unexpected commands: wanted %q, found %q", want, cmds)
+	}
+}
+
+func TestJoinProcesses(t *testing.T) {
+	t.Parallel()
+
+	socks, err := netstat.ParseDarwinOutput(bytes.NewBufferString(`
+tcp4       0      0  192.168.1.5.54321      17.57.146.20.5223      ESTABLISHED 131072 131768    412      0 0x0102 0x00000020
+tcp4       0      0  127.0.0.1.49153        *.*                    LISTEN             0            0  131072  131072     rapportd:413    00100 00000006
+tcp4       0      0  192.168.1.5.54322      17.57.146.20.5223      TIME_WAIT   131072 131768      0      0 0x0102 0x00000020
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	table := ProcessTable{
+		412: {Pid: 412, PPid: 1, Cmd: "/System/Library/PrivateFrameworks/ApplePushService.framework/apsd"},
+		413: {Pid: 413, PPid: 1, Cmd: "/usr/libexec/rapportd"},
+	}
+
+	set := joinProcesses(mapNetstatDarwin(socks), table)
+	cmds := []string{}
+	for _, v := range set {
+		cmds = append(cmds, v.Cmd)
+	}
+	want := []string{"apsd", "rapportd", ""}
+	if !reflect.DeepEqual(want, cmds) {
+		t.Fatalf("Unexpected commands: wanted %q, found %q", want, cmds)
+	}
+}
@@ -16,20 +16,37 @@
 package onf
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/jecoz/lsaddr/internal"
 )
 
-// ONF represents an open network file.
+// ONF represents an open network file. Unix domain sockets are reported
+// with addresses of the "unix" network, which contain the socket path.
+// Missing addresses, e.g. the peer of a listening socket, are never nil,
+// but empty addresses of the network of the socket, see NewUnixAddr and
+// internal.NewAddr.
 type ONF struct {
 	Raw       string   // raw string that produced this result
 	Cmd       string   // command associated with Pid
-	Pid       int      // pid of the owner
+	Pid       int      // pid of the owner, 0 if unattributed
+	Fd        int      // file descriptor used by Pid, -1 if unknown
+	User      string   // owner user name, or its uid when the name is not known; empty for parsed snapshots that do not report it
+	Uid       int      // owner user id, -1 if unknown
+	Family    Family   // address family
+	Proto     string   // transport protocol: tcp, udp or unix
+	State     State    // connection state, tcp and unix sockets only
+	Inode     uint64   // socket inode, 0 if unknown
 	Src       net.Addr // source address
 	Dst       net.Addr // destination address
+	PeerPid   int      // pid of the owner of the peer unix socket, 0 if unknown
 	CreatedAt time.Time
 }
 
@@ -37,17 +54,97 @@ func (f ONF) String() string {
 	return fmt.Sprintf("{Cmd: %s, Pid: %d, Conn: %v->%v}", f.Cmd, f.Pid, f.Src, f.Dst)
 }
 
-// FetchAll retrieves the complete list of open network files. It does
-// so using an external tool, `netstat` for windows and `lsof` for unix
-// based systems.
-func FetchAll() ([]ONF, error) {
-	// fetchAll implementations may be found insiede the
-	// runtime_*.go files.
-	return fetchAll()
+// Family is the address family of an open network file.
+type Family string
+
+// Supported address families.
+const (
+	FamilyUnknown Family = ""
+	FamilyIPv4    Family = "ipv4"
+	FamilyIPv6    Family = "ipv6"
+	FamilyUnix    Family = "unix"
+)
+
+// ParseFamily returns the family named "s", accepting the names used by
+// lsof, e.g. "IPv4". Unrecognised families are returned as FamilyUnknown.
+func ParseFamily(s string) Family {
+	switch f := Family(strings.ToLower(s)); f {
+	case FamilyIPv4, FamilyIPv6, FamilyUnix:
+		return f
+	default:
+		return FamilyUnknown
+	}
+}
+
+// familyOf guesses the family of "addr" from its host, ignoring IPv6
+// zones. Wildcard hosts, e.g. "*", have no family.
+func familyOf(addr net.Addr) Family {
+	if addr == nil {
+		return FamilyUnknown
+	}
+	if addr.Network() == "unix" {
+		return FamilyUnix
+	}
+	ip := addrIP(addr)
+	switch {
+	case ip == nil:
+		return FamilyUnknown
+	case ip.To4() != nil:
+		return FamilyIPv4
+	default:
+		return FamilyIPv6
+	}
+}
+
+func ipFamily(ipv6 bool) Family {
+	if ipv6 {
+		return FamilyIPv6
+	}
+	return FamilyIPv4
+}
+
+// NewUnixAddr returns the address of a unix domain socket bound to
+// "path", which is empty for unnamed sockets.
+func NewUnixAddr(path string) net.Addr {
+	return internal.NewAddr("unix", path)
+}
+
+// IsUnix reports whether the open network file is a unix domain socket.
+func (f ONF) IsUnix() bool {
+	return f.Src != nil && f.Src.Network() == "unix"
+}
+
+// Attributed reports whether the owner of the open network file is known.
+// Some backends may report sockets which belong to processes that could
+// not be inspected, e.g. because of missing privileges.
+func (f ONF) Attributed() bool {
+	return f.Pid > 0
+}
+
+// FetchAll retrieves the complete list of open network files. On linux
+// it queries the kernel through netlink's sock_diag, falling back to the
+// socket tables exposed in /proc/net, to `ss` and then to `lsof` when they
+// are not available. Other systems rely on external tools: PowerShell's
+// Get-NetTCPConnection, or `netstat` when it is not available, for
+// windows, `netstat`, or `lsof` when unix domain sockets are requested,
+// for macOS, `sockstat`, or `lsof` when it is not available, for the BSDs
+// and `lsof` for the remaining unix based systems.
+// It uses the fetcher registered as DefaultSource, configured with "opts".
+// External tools are killed when "ctx" is done.
+func FetchAll(ctx context.Context, opts Options) ([]ONF, error) {
+	f, err := Lookup(DefaultSource)
+	if err != nil {
+		return []ONF{}, err
+	}
+	return f.Fetch(ctx, opts)
 }
 
 // Filter takes `pivot` and creates a compiled regex out of it. It then uses
 // it to filter `set`, removing every open network file that do not match.
+// The regex is matched against the raw output that produced each result
+// and against each of its values, i.e. command, pid and addresses, as
+// some backends (i.e. procfs) produce raw outputs that do not contain
+// process information.
 // If an error occurs, it is returned together with the original list.
 func Filter(set []ONF, pivot string) ([]ONF, error) {
 	if pivot == "" || pivot == "*" {
@@ -61,7 +158,7 @@ func Filter(set []ONF, pivot string) ([]ONF, error) {
 	}
 	acc := make([]ONF, 0, len(set))
 	for _, v := range set {
-		if !rgx.MatchString(v.Raw) {
+		if !rgx.MatchString(v.Raw) && !matchValues(rgx, v) {
 			log.Printf("Filtering open network file: %v", v)
 			continue
 		}
@@ -69,3 +166,20 @@ func Filter(set []ONF, pivot string) ([]ONF, error) {
 	}
 	return acc, nil
 }
+
+// matchValues reports whether "rgx" matches one of the values of "f".
+// Labels and separators of its string representation are not matched.
+func matchValues(rgx *regexp.Regexp, f ONF) bool {
+	values := []string{f.Cmd, strconv.Itoa(f.Pid)}
+	for _, a := range []net.Addr{f.Src, f.Dst} {
+		if a != nil {
+			values = append(values, a.String())
+		}
+	}
+	for _, v := range values {
+		if v != "" && rgx.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf_test
+
+import (
+	"testing"
+
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/onf"
+)
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	set := []onf.ONF{
+		{Cmd: "sshd", Pid: 812, Src: internal.NewAddr("tcp", "10.0.0.1:22"), Dst: internal.NewAddr("tcp", "10.0.0.2:51234")},
+		{Pid: 0, Src: internal.NewAddr("tcp", "[::1]:631"), Dst: internal.NewAddr("tcp", "")},
+	}
+	tt := []struct {
+		pivot string
+		n     int
+	}{
+		{pivot: "sshd", n: 1},
+		{pivot: "^812$", n: 1},
+		{pivot: "51234", n: 1},
+		{pivot: ":631$", n: 1},
+		{pivot: "^0$", n: 1},
+		// Labels and separators are not values.
+		{pivot: "Pid", n: 0},
+		{pivot: "Cmd", n: 0},
+		{pivot: "->", n: 0},
+		{pivot: "[{}]", n: 0},
+	}
+	for _, v := range tt {
+		matched, err := onf.Filter(set, v.pivot)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(matched) != v.n {
+			t.Fatalf("%q: expected %d matches, found %d: %v", v.pivot, v.n, len(matched), matched)
+		}
+	}
+}
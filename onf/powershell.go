@@ -0,0 +1,60 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"context"
+	"time"
+
+	"github.com/jecoz/lsaddr/powershell"
+)
+
+func init() {
+	Register("powershell", FetcherFunc(fetchPowerShell))
+}
+
+// fetchPowerShell collects connections with Get-NetTCPConnection and
+// Get-NetUDPEndpoint, naming their owners using tasklist.
+func fetchPowerShell(ctx context.Context, opts Options) ([]ONF, error) {
+	set, err := powershell.Run(ctx, opts.Options)
+	if err != nil {
+		return []ONF{}, err
+	}
+	return nameOwners(ctx, mapPowerShell(set), opts), nil
+}
+
+func mapPowerShell(set []powershell.Connection) []ONF {
+	mapped := make([]ONF, len(set))
+	for i, v := range set {
+		created := v.CreationTime
+		if created.IsZero() {
+			created = time.Now()
+		}
+		mapped[i] = ONF{
+			Raw:       v.Raw,
+			Pid:       v.OwningProcess,
+			Fd:        -1,
+			Uid:       -1,
+			Family:    familyOf(v.SrcAddr),
+			Proto:     v.Proto,
+			State:     ParseState(v.State),
+			Src:       v.SrcAddr,
+			Dst:       v.DstAddr,
+			CreatedAt: created,
+		}
+	}
+	return mapped
+}
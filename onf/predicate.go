@@ -0,0 +1,274 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Predicate reports whether an open network file satisfies a condition.
+type Predicate func(ONF) bool
+
+// Select returns the open network files of "set" that satisfy every
+// predicate in "preds".
+func Select(set []ONF, preds ...Predicate) []ONF {
+	acc := make([]ONF, 0, len(set))
+Loop:
+	for _, v := range set {
+		for _, p := range preds {
+			if !p(v) {
+				continue Loop
+			}
+		}
+		acc = append(acc, v)
+	}
+	return acc
+}
+
+// Not returns the negation of "p".
+func Not(p Predicate) Predicate {
+	return func(f ONF) bool { return !p(f) }
+}
+
+// SrcInCIDR is satisfied by the open network files whose source ip is
+// contained in at least one of "nets".
+func SrcInCIDR(nets ...*net.IPNet) Predicate {
+	return func(f ONF) bool { return inCIDR(addrIP(f.Src), nets) }
+}
+
+// DstInCIDR is satisfied by the open network files whose destination ip
+// is contained in at least one of "nets".
+func DstInCIDR(nets ...*net.IPNet) Predicate {
+	return func(f ONF) bool { return inCIDR(addrIP(f.Dst), nets) }
+}
+
+func inCIDR(ip net.IP, nets []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, v := range nets {
+		if v.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseCIDR parses "s" as a CIDR, e.g. "10.20.0.0/16". Plain ips are
+// accepted too, and are converted into a network containing only them.
+func ParseCIDR(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(strings.Trim(s, "[]"))
+		if ip == nil {
+			return nil, fmt.Errorf("invalid CIDR address: %s", s)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipnet, err := net.ParseCIDR(s)
+	return ipnet, err
+}
+
+// PortRange is an inclusive range of ports, e.g. {Lo: 0, Hi: 0} matches
+// port 0 only.
+type PortRange struct {
+	Lo, Hi uint16
+}
+
+// ParsePortRange parses either a single port, e.g. "443", or an inclusive
+// range, e.g. "30000-32767".
+func ParsePortRange(s string) (PortRange, error) {
+	lo, hi := s, s
+	if i := strings.Index(s, "-"); i >= 0 {
+		lo, hi = s[:i], s[i+1:]
+	}
+	l, err := strconv.ParseUint(strings.TrimSpace(lo), 10, 16)
+	if err != nil {
+		return PortRange{}, fmt.Errorf("invalid port range %s: %w", s, err)
+	}
+	h, err := strconv.ParseUint(strings.TrimSpace(hi), 10, 16)
+	if err != nil {
+		return PortRange{}, fmt.Errorf("invalid port range %s: %w", s, err)
+	}
+	if h < l {
+		return PortRange{}, fmt.Errorf("invalid port range %s: %d is lower than %d", s, h, l)
+	}
+	return PortRange{Lo: uint16(l), Hi: uint16(h)}, nil
+}
+
+// Contains reports whether "port" is in the range.
+func (r PortRange) Contains(port int) bool {
+	return int(r.Lo) <= port && port <= int(r.Hi)
+}
+
+func (r PortRange) String() string {
+	if r.Lo == r.Hi {
+		return strconv.Itoa(int(r.Lo))
+	}
+	return fmt.Sprintf("%d-%d", r.Lo, r.Hi)
+}
+
+// SrcPortIn is satisfied by the open network files whose source port is
+// contained in at least one of "ranges".
+func SrcPortIn(ranges ...PortRange) Predicate {
+	return func(f ONF) bool { return inPortRange(f.Src, ranges) }
+}
+
+// DstPortIn is satisfied by the open network files whose destination
+// port is contained in at least one of "ranges".
+func DstPortIn(ranges ...PortRange) Predicate {
+	return func(f ONF) bool { return inPortRange(f.Dst, ranges) }
+}
+
+func inPortRange(addr net.Addr, ranges []PortRange) bool {
+	port, ok := addrPort(addr)
+	if !ok {
+		return false
+	}
+	for _, v := range ranges {
+		if v.Contains(int(port)) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddrClass is the class of an ip address, i.e. its scope.
+type AddrClass int
+
+// Supported address classes. Reserved collects the special purpose
+// ranges that do not fit into the other classes, e.g. documentation
+// networks, shared address space or broadcast.
+const (
+	ClassUnknown AddrClass = iota
+	ClassUnspecified
+	ClassLoopback
+	ClassLinkLocal
+	ClassPrivate
+	ClassMulticast
+	ClassReserved
+	ClassPublic
+)
+
+var classNames = [...]string{
+	ClassUnknown:     "unknown",
+	ClassUnspecified: "unspecified",
+	ClassLoopback:    "loopback",
+	ClassLinkLocal:   "link-local",
+	ClassPrivate:     "private",
+	ClassMulticast:   "multicast",
+	ClassReserved:    "reserved",
+	ClassPublic:      "public",
+}
+
+func (c AddrClass) String() string {
+	if c < 0 || int(c) >= len(classNames) {
+		return classNames[ClassUnknown]
+	}
+	return classNames[c]
+}
+
+// ParseAddrClass returns the class named "s". Unrecognised classes are
+// returned as ClassUnknown.
+func ParseAddrClass(s string) AddrClass {
+	s = strings.Replace(strings.ToLower(strings.TrimSpace(s)), "_", "-", -1)
+	for i, v := range classNames {
+		if v == s {
+			return AddrClass(i)
+		}
+	}
+	return ClassUnknown
+}
+
+func mustParseCIDRs(ss ...string) []*net.IPNet {
+	acc := make([]*net.IPNet, len(ss))
+	for i, v := range ss {
+		_, ipnet, err := net.ParseCIDR(v)
+		if err != nil {
+			panic(err)
+		}
+		acc[i] = ipnet
+	}
+	return acc
+}
+
+var (
+	// privateNets are defined by RFC 1918 and RFC 4193 (ULA).
+	privateNets = mustParseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7")
+	// reservedNets are the special purpose ranges of RFC 6890 which are
+	// not covered by the other classes.
+	reservedNets = mustParseCIDRs(
+		"0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "192.0.2.0/24",
+		"198.18.0.0/15", "198.51.100.0/24", "203.0.113.0/24", "240.0.0.0/4",
+		"64:ff9b::/96", "100::/64", "2001::/23", "2001:db8::/32",
+	)
+)
+
+// ClassOf returns the class of "ip".
+func ClassOf(ip net.IP) AddrClass {
+	switch {
+	case ip == nil:
+		return ClassUnknown
+	case ip.IsUnspecified():
+		return ClassUnspecified
+	case ip.IsLoopback():
+		return ClassLoopback
+	case ip.IsMulticast():
+		return ClassMulticast
+	case ip.IsLinkLocalUnicast():
+		return ClassLinkLocal
+	case inCIDR(ip, privateNets):
+		return ClassPrivate
+	case inCIDR(ip, reservedNets), ip.Equal(net.IPv4bcast):
+		return ClassReserved
+	default:
+		return ClassPublic
+	}
+}
+
+// ClassOfAddr returns the class of the ip of "addr". Unix domain socket
+// addresses and wildcard hosts, e.g. "*:80", are of ClassUnknown.
+func ClassOfAddr(addr net.Addr) AddrClass {
+	return ClassOf(addrIP(addr))
+}
+
+// SrcClass is satisfied by the open network files whose source ip
+// belongs to one of "classes".
+func SrcClass(classes ...AddrClass) Predicate {
+	return func(f ONF) bool { return inClass(addrIP(f.Src), classes) }
+}
+
+// DstClass is satisfied by the open network files whose destination ip
+// belongs to one of "classes".
+func DstClass(classes ...AddrClass) Predicate {
+	return func(f ONF) bool { return inClass(addrIP(f.Dst), classes) }
+}
+
+func inClass(ip net.IP, classes []AddrClass) bool {
+	c := ClassOf(ip)
+	for _, v := range classes {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/jecoz/lsaddr/onf"
+)
+
+func TestClassOf(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		ip    string
+		class onf.AddrClass
+	}{
+		{"0.0.0.0", onf.ClassUnspecified},
+		{"::", onf.ClassUnspecified},
+		{"127.0.0.1", onf.ClassLoopback},
+		{"::1", onf.ClassLoopback},
+		{"169.254.10.1", onf.ClassLinkLocal},
+		{"fe80::1", onf.ClassLinkLocal},
+		{"10.20.1.1", onf.ClassPrivate},
+		{"172.31.255.1", onf.ClassPrivate},
+		{"192.168.0.61", onf.ClassPrivate},
+		{"fd12:3456::1", onf.ClassPrivate},
+		{"::ffff:192.168.0.1", onf.ClassPrivate},
+		{"224.0.0.251", onf.ClassMulticast},
+		{"ff02::fb", onf.ClassMulticast},
+		{"100.64.0.1", onf.ClassReserved},
+		{"255.255.255.255", onf.ClassReserved},
+		{"2001:db8::1", onf.ClassReserved},
+		{"172.32.0.1", onf.ClassPublic},
+		{"35.186.224.25", onf.ClassPublic},
+		{"2a00:1450:4002::200e", onf.ClassPublic},
+	}
+	for _, v := range tt {
+		if c := onf.ClassOf(net.ParseIP(v.ip)); c != v.class {
+			t.Fatalf("%s: expected class %v, found %v", v.ip, v.class, c)
+		}
+	}
+	assert(t, onf.ClassUnknown, onf.ClassOf(nil))
+	assert(t, onf.ClassLinkLocal, onf.ParseAddrClass("link_local"))
+	assert(t, onf.ClassUnknown, onf.ParseAddrClass("bogus"))
+}
+
+func TestParsePortRange(t *testing.T) {
+	t.Parallel()
+
+	r, err := onf.ParsePortRange("30000-32767")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, onf.PortRange{Lo: 30000, Hi: 32767}, r)
+	assert(t, "30000-32767", r.String())
+	assert(t, true, r.Contains(30000))
+	assert(t, false, r.Contains(32768))
+
+	if r, err = onf.ParsePortRange("443"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, onf.PortRange{Lo: 443, Hi: 443}, r)
+	assert(t, false, onf.PortRange{}.Contains(1))
+	assert(t, true, onf.PortRange{}.Contains(0))
+
+	for _, v := range []string{"", "x", "80-", "443-80", "70000"} {
+		if _, err := onf.ParsePortRange(v); err == nil {
+			t.Fatalf("%s: expected error", v)
+		}
+	}
+}
+
+func TestParseCIDR(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []struct{ in, out string }{
+		{"10.20.0.0/16", "10.20.0.0/16"},
+		{"10.20.0.1", "10.20.0.1/32"},
+		{"[::1]", "::1/128"},
+	} {
+		ipnet, err := onf.ParseCIDR(v.in)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", v.in, err)
+		}
+		assert(t, v.out, ipnet.String())
+	}
+	if _, err := onf.ParseCIDR("10.20.0.0/33"); err == nil {
+		t.Fatalf("Expected error parsing invalid CIDR")
+	}
+}
+
+func TestSelect(t *testing.T) {
+	t.Parallel()
+
+	vpc, _ := onf.ParseCIDR("10.20.0.0/16")
+	tt := []struct {
+		preds []onf.Predicate
+		pids  []int
+	}{
+		{nil, []int{614, 614, 676, 1100}},
+		{[]onf.Predicate{onf.DstInCIDR(vpc)}, []int{614}},
+		{[]onf.Predicate{onf.Not(onf.DstInCIDR(vpc))}, []int{614, 676, 1100}},
+		{[]onf.Predicate{onf.SrcInCIDR(vpc), onf.DstPortIn(onf.PortRange{Lo: 80, Hi: 80})}, []int{614}},
+		{[]onf.Predicate{onf.SrcPortIn(onf.PortRange{Lo: 5000, Hi: 6000}, onf.PortRange{Lo: 58282, Hi: 58282})}, []int{614, 676}},
+		{[]onf.Predicate{onf.DstClass(onf.ClassPublic)}, []int{614}},
+		{[]onf.Predicate{onf.SrcClass(onf.ClassLoopback, onf.ClassUnknown)}, []int{676, 1100}},
+	}
+	for _, v := range tt {
+		pids := []int{}
+		for _, f := range onf.Select(querySet, v.preds...) {
+			pids = append(pids, f.Pid)
+		}
+		assert(t, v.pids, pids)
+	}
+}
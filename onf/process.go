@@ -0,0 +1,176 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jecoz/lsaddr/procfs"
+	"github.com/jecoz/lsaddr/ps"
+	"github.com/jecoz/lsaddr/tool"
+)
+
+// Process is an entry of the process table.
+type Process struct {
+	Pid  int
+	PPid int
+	Cmd  string
+}
+
+// ProcessTable maps pids to their processes.
+type ProcessTable map[int]Process
+
+// FetchProcesses builds the process table of the running system. On
+// linux it is read from /proc/<pid>/stat, other systems run `ps`.
+// Only the timeout of "opts" is used, as the remaining execution options
+// are meant for the tool collecting open network files.
+func FetchProcesses(ctx context.Context, opts Options) (ProcessTable, error) {
+	// fetchProcesses implementations may be found inside the
+	// runtime_*.go files.
+	return fetchProcesses(ctx, opts)
+}
+
+func fetchPs(ctx context.Context, opts Options) (ProcessTable, error) {
+	set, err := ps.Run(ctx, tool.Options{Timeout: opts.Timeout})
+	if err != nil {
+		return ProcessTable{}, err
+	}
+	t := make(ProcessTable, len(set))
+	for _, v := range set {
+		t[v.Pid] = Process{Pid: v.Pid, PPid: v.PPid, Cmd: v.Comm}
+	}
+	return t, nil
+}
+
+func fetchProcStat(ctx context.Context, opts Options) (ProcessTable, error) {
+	set, err := procfs.ScanProcesses()
+	if err != nil {
+		return ProcessTable{}, err
+	}
+	t := make(ProcessTable, len(set))
+	for _, v := range set {
+		t[v.Pid] = Process{Pid: v.Pid, PPid: v.PPid, Cmd: v.Comm}
+	}
+	return t, nil
+}
+
+// Children returns the pids of the direct children of "pid", sorted.
+func (t ProcessTable) Children(pid int) []int {
+	acc := []int{}
+	for _, v := range t {
+		if v.PPid == pid && v.Pid != pid {
+			acc = append(acc, v.Pid)
+		}
+	}
+	sort.Ints(acc)
+	return acc
+}
+
+// Descendants returns the set of "pids" together with all their
+// descendants.
+func (t ProcessTable) Descendants(pids ...int) map[int]bool {
+	children := make(map[int][]int, len(t))
+	for _, v := range t {
+		if v.PPid != v.Pid {
+			children[v.PPid] = append(children[v.PPid], v.Pid)
+		}
+	}
+	acc := make(map[int]bool, len(pids))
+	queue := append([]int{}, pids...)
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		if acc[pid] {
+			continue
+		}
+		acc[pid] = true
+		queue = append(queue, children[pid]...)
+	}
+	return acc
+}
+
+// Stubs returns an open network file without addresses for each process
+// of the table. Filtering them together with the real open network files
+// allows matching the root of a process tree even when it does not own
+// any socket itself.
+func (t ProcessTable) Stubs() []ONF {
+	acc := make([]ONF, 0, len(t))
+	for _, v := range t {
+		acc = append(acc, ONF{Cmd: v.Cmd, Pid: v.Pid, Fd: -1, Uid: -1})
+	}
+	sort.Slice(acc, func(i, j int) bool { return acc[i].Pid < acc[j].Pid })
+	return acc
+}
+
+// Expand returns the open network files of "set" owned by the processes
+// of "matched", or by any of their descendants.
+func (t ProcessTable) Expand(set, matched []ONF) []ONF {
+	roots := make([]int, 0, len(matched))
+	for _, v := range matched {
+		if v.Attributed() {
+			roots = append(roots, v.Pid)
+		}
+	}
+	pids := t.Descendants(roots...)
+	acc := make([]ONF, 0, len(set))
+	for _, v := range set {
+		if pids[v.Pid] {
+			acc = append(acc, v)
+		}
+	}
+	return acc
+}
+
+// minCmdLen is the length of the command names printed by lsof by
+// default, which truncates longer ones.
+const minCmdLen = 9
+
+// RunsExecutable is satisfied by the open network files owned by
+// processes running one of the executables at "paths". The full path of
+// the executable is taken from "t" when available, i.e. on systems using
+// `ps`. Otherwise command names are compared with the base name of the
+// paths, allowing for the truncation applied by tools such as lsof.
+func RunsExecutable(t ProcessTable, paths ...string) Predicate {
+	full := make(map[string]bool, len(paths))
+	names := make([]string, 0, len(paths))
+	for _, v := range paths {
+		full[v] = true
+		names = append(names, filepath.Base(v))
+	}
+	matchName := func(cmd string) bool {
+		cmd = strings.Replace(cmd, `\x20`, " ", -1)
+		if cmd == "" {
+			return false
+		}
+		for _, v := range names {
+			if v == cmd || len(cmd) >= minCmdLen && strings.HasPrefix(v, cmd) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(f ONF) bool {
+		if p, ok := t[f.Pid]; ok && f.Attributed() {
+			if full[p.Cmd] || matchName(filepath.Base(p.Cmd)) {
+				return true
+			}
+		}
+		return matchName(f.Cmd)
+	}
+}
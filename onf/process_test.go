@@ -0,0 +1,94 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/jecoz/lsaddr/onf"
+)
+
+var processTable = onf.ProcessTable{
+	1:   {Pid: 1, PPid: 0, Cmd: "launchd"},
+	120: {Pid: 120, PPid: 1, Cmd: "Code"},
+	121: {Pid: 121, PPid: 120, Cmd: "Code Helper"},
+	122: {Pid: 122, PPid: 121, Cmd: "Code Helper (Renderer)"},
+	130: {Pid: 130, PPid: 1, Cmd: "Spotify"},
+}
+
+func TestProcessTable_Descendants(t *testing.T) {
+	t.Parallel()
+
+	pids := []int{}
+	for k := range processTable.Descendants(120) {
+		pids = append(pids, k)
+	}
+	sort.Ints(pids)
+	assert(t, []int{120, 121, 122}, pids)
+	assert(t, 5, len(processTable.Descendants(1)))
+	assert(t, 1, len(processTable.Descendants(999)))
+	assert(t, []int{120, 130}, processTable.Children(1))
+}
+
+func TestProcessTable_Expand(t *testing.T) {
+	t.Parallel()
+
+	set := []onf.ONF{
+		{Cmd: "Code Helper", Pid: 121, Fd: 20},
+		{Cmd: "Code Helper (Renderer)", Pid: 122, Fd: 31},
+		{Cmd: "Spotify", Pid: 130, Fd: 40},
+		{Fd: -1},
+	}
+	q, err := onf.ParseQuery(`cmd = Code`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	matched := q.Filter(append(processTable.Stubs(), set...))
+	assert(t, 1, len(matched))
+	fds := []int{}
+	for _, v := range processTable.Expand(set, matched) {
+		fds = append(fds, v.Fd)
+	}
+	assert(t, []int{20, 31}, fds)
+	assert(t, 0, len(processTable.Expand(set, []onf.ONF{{Fd: -1}})))
+}
+
+func TestRunsExecutable(t *testing.T) {
+	t.Parallel()
+
+	execs := []string{
+		"/Applications/Visual Studio Code.app/Contents/MacOS/Electron",
+		"/Applications/Visual Studio Code.app/Contents/Frameworks/Code Helper (Renderer).app/Contents/MacOS/Code Helper (Renderer)",
+	}
+	table := onf.ProcessTable{
+		912: {Pid: 912, PPid: 1, Cmd: execs[0]},
+		930: {Pid: 930, PPid: 912, Cmd: "/usr/local/bin/Electron"},
+	}
+	set := []onf.ONF{
+		{Cmd: "Electron", Pid: 912, Fd: 1},
+		{Cmd: "Electron", Pid: 930, Fd: 2},
+		{Cmd: `Code\x20Help`, Pid: 940, Fd: 3},
+		{Cmd: "Code Helper (Renderer)", Pid: 941, Fd: 4},
+		{Cmd: "Code", Pid: 942, Fd: 5},
+		{Cmd: "Spotify", Pid: 950, Fd: 6},
+	}
+	fds := []int{}
+	for _, v := range onf.Select(set, onf.RunsExecutable(table, execs...)) {
+		fds = append(fds, v.Fd)
+	}
+	assert(t, []int{1, 2, 3, 4}, fds)
+}
@@ -0,0 +1,122 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jecoz/lsaddr/procfs"
+)
+
+func init() {
+	Register("procfs", FetcherFunc(fetchProcfs))
+}
+
+// fetchProcfs reads the kernel socket tables and attributes each socket
+// to the processes holding a file descriptor pointing to it. Unix domain
+// sockets are included when requested by "opts", but without peers as
+// they are not reported by /proc/net/unix.
+func fetchProcfs(ctx context.Context, opts Options) ([]ONF, error) {
+	set, err := procfs.Run()
+	if err != nil {
+		return []ONF{}, err
+	}
+	var unix []procfs.UnixSocket
+	if opts.Unix {
+		if unix, err = procfs.RunUnix(); err != nil {
+			return []ONF{}, err
+		}
+	}
+	idx := scanInodes()
+	return resolveUsers(append(mapProcfs(set, idx), mapProcfsUnix(unix, idx)...)), nil
+}
+
+func mapProcfsUnix(set []procfs.UnixSocket, idx procfs.InodeIndex) []ONF {
+	mapped := make([]ONF, 0, len(set))
+	for _, v := range set {
+		f := ONF{
+			Raw:       v.Raw,
+			Uid:       -1,
+			Family:    FamilyUnix,
+			Proto:     "unix",
+			State:     unixState(v),
+			Src:       NewUnixAddr(v.Path),
+			Dst:       NewUnixAddr(""),
+			CreatedAt: time.Now(),
+		}
+		mapped = attribute(mapped, f, idx, v.Inode)
+	}
+	return mapped
+}
+
+func unixState(s procfs.UnixSocket) State {
+	switch {
+	case s.Listening:
+		return StateListen
+	case s.Connected:
+		return StateEstablished
+	default:
+		return StateUnknown
+	}
+}
+
+func mapProcfs(set []procfs.Socket, idx procfs.InodeIndex) []ONF {
+	mapped := make([]ONF, 0, len(set))
+	for _, v := range set {
+		f := ONF{
+			Raw:       v.Raw,
+			Uid:       v.Uid,
+			Family:    ipFamily(v.IPv6),
+			Proto:     v.Network,
+			State:     socketState(v.Network, v.State),
+			Src:       v.SrcAddr,
+			Dst:       v.DstAddr,
+			CreatedAt: time.Now(),
+		}
+		mapped = attribute(mapped, f, idx, v.Inode)
+	}
+	return mapped
+}
+
+func scanInodes() procfs.InodeIndex {
+	idx, err := procfs.ScanInodes()
+	if err != nil {
+		log.Printf("unable to attribute sockets to processes: %v", err)
+	}
+	return idx
+}
+
+// attribute appends to "acc" a copy of "f" for each process owning the
+// socket identified by "inode". Sockets that cannot be attributed are
+// appended anyway, without owner.
+func attribute(acc []ONF, f ONF, idx procfs.InodeIndex, inode uint64) []ONF {
+	f.Fd = -1
+	f.Inode = inode
+	owners := idx.Lookup(inode)
+	if len(owners) == 0 {
+		log.Printf("unable to attribute socket with inode %d", inode)
+		return append(acc, f)
+	}
+	for _, o := range owners {
+		f.Pid = o.Pid
+		f.Cmd = o.Cmd
+		f.Fd = o.Fd
+		acc = append(acc, f)
+	}
+	return acc
+}
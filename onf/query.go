@@ -0,0 +1,701 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Query is a compiled filter expression, which selects open network
+// files by their fields instead of their raw output. Expressions are made
+// of comparisons combined with "and", "or", "not" and parentheses, e.g.
+//
+//	cmd ~ "Spotify" and dport in (80, 443) and state = established and not dst in 10.0.0.0/8
+//
+// Comparisons are in the form ``field operator value''. Supported
+// operators are "=", "!=", "~" and "!~" (regular expressions), "<", "<=",
+// ">", ">=" (numeric fields only) and "in", followed by either a single
+// value or a parenthesized list. Numeric fields match inclusive ranges,
+// e.g. 30000-32767. Addresses, i.e. src and dst, match CIDRs, ips or
+// their complete string representation, while their classes, i.e.
+// sclass and dclass, are the names of the AddrClass values, e.g. public.
+type Query struct {
+	src  string
+	root matcher
+}
+
+// ParseQuery compiles "s" into a Query. Errors are of type *SyntaxError.
+func ParseQuery(s string) (*Query, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{query: s, toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %s, expected \"and\", \"or\" or end of query", t)
+	}
+	return &Query{src: s, root: root}, nil
+}
+
+// Match reports whether "f" satisfies the query.
+func (q *Query) Match(f ONF) bool {
+	return q.root.match(f)
+}
+
+// Filter returns the open network files of "set" that satisfy the query.
+func (q *Query) Filter(set []ONF) []ONF {
+	acc := make([]ONF, 0, len(set))
+	for _, v := range set {
+		if q.Match(v) {
+			acc = append(acc, v)
+		}
+	}
+	return acc
+}
+
+func (q *Query) String() string {
+	return q.src
+}
+
+// SyntaxError describes an invalid query.
+type SyntaxError struct {
+	Query string
+	Pos   int // byte offset in Query
+	Msg   string
+}
+
+// Error returns the description of the error, followed by the query and
+// a caret pointing at the offending position.
+func (e *SyntaxError) Error() string {
+	col := utf8.RuneCountInString(e.Query[:e.Pos])
+	return fmt.Sprintf("syntax error at column %d: %s\n\t%s\n\t%s^", col+1, e.Msg, e.Query, strings.Repeat(" ", col))
+}
+
+// Lexer.
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokKind
+	val  string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return strconv.Quote(t.val)
+	default:
+		return fmt.Sprintf("\"%s\"", t.val)
+	}
+}
+
+// keyword reports whether "t" is the keyword "kw", which is case
+// insensitive.
+func (t token) keyword(kw string) bool {
+	return t.kind == tokWord && strings.EqualFold(t.val, kw)
+}
+
+const opChars = "=!~<>"
+
+func isWordRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(opChars+"(),\"'", r)
+}
+
+func lex(s string) ([]token, error) {
+	toks := []token{}
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '(':
+			toks = append(toks, token{tokLParen, "(", i})
+			i++
+		case r == ')':
+			toks = append(toks, token{tokRParen, ")", i})
+			i++
+		case r == ',':
+			toks = append(toks, token{tokComma, ",", i})
+			i++
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(s) && s[end] != byte(r) {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, &SyntaxError{Query: s, Pos: i, Msg: "unterminated string"}
+			}
+			val := s[i+1 : end]
+			if r == '"' {
+				v, err := strconv.Unquote(s[i : end+1])
+				if err != nil {
+					return nil, &SyntaxError{Query: s, Pos: i, Msg: "invalid string: " + err.Error()}
+				}
+				val = v
+			}
+			toks = append(toks, token{tokString, val, i})
+			i = end + 1
+		case strings.ContainsRune(opChars, r):
+			end := i + 1
+			for end < len(s) && strings.IndexByte(opChars, s[end]) >= 0 {
+				end++
+			}
+			op := s[i:end]
+			switch op {
+			case "=", "!=", "~", "!~", "<", "<=", ">", ">=":
+			case "==":
+				op = "="
+			default:
+				return nil, &SyntaxError{Query: s, Pos: i, Msg: fmt.Sprintf("unknown operator \"%s\"", op)}
+			}
+			toks = append(toks, token{tokOp, op, i})
+			i = end
+		default:
+			end := i
+			for end < len(s) {
+				r, size := utf8.DecodeRuneInString(s[end:])
+				if !isWordRune(r) {
+					break
+				}
+				end += size
+			}
+			toks = append(toks, token{tokWord, s[i:end], i})
+			i = end
+		}
+	}
+	return append(toks, token{tokEOF, "", len(s)}), nil
+}
+
+// Parser.
+
+type parser struct {
+	query string
+	toks  []token
+	pos   int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return &SyntaxError{Query: p.query, Pos: t.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) parseOr() (matcher, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orMatcher{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (matcher, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andMatcher{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (matcher, error) {
+	t := p.peek()
+	switch {
+	case t.keyword("not"):
+		p.next()
+		m, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notMatcher{m}, nil
+	case t.kind == tokLParen:
+		p.next()
+		m, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokRParen {
+			return nil, p.errorf(t, "unexpected %s, expected \")\"", t)
+		}
+		return m, nil
+	default:
+		return p.parseComparison()
+	}
+}
+
+func (p *parser) parseComparison() (matcher, error) {
+	t := p.next()
+	if t.kind != tokWord || t.keyword("and") || t.keyword("or") || t.keyword("in") {
+		return nil, p.errorf(t, "unexpected %s, expected a field name", t)
+	}
+	fd, ok := queryFields[strings.ToLower(t.val)]
+	if !ok {
+		return nil, p.errorf(t, "unknown field \"%s\", available fields are: %s", t.val, strings.Join(QueryFields(), ", "))
+	}
+
+	opTok := p.next()
+	op := opTok.val
+	negate := false
+	switch {
+	case opTok.kind == tokOp:
+	case opTok.keyword("in"):
+		op = "in"
+	case opTok.keyword("not") && p.peek().keyword("in"):
+		p.next()
+		op, negate = "in", true
+	default:
+		return nil, p.errorf(opTok, "unexpected %s, expected an operator after \"%s\"", opTok, t.val)
+	}
+
+	vals, err := p.parseValues(op == "in")
+	if err != nil {
+		return nil, err
+	}
+	m, err := fd.compile(op, vals)
+	if err != nil {
+		if se, ok := err.(*SyntaxError); ok {
+			se.Query = p.query
+			return nil, se
+		}
+		return nil, p.errorf(opTok, "%v", err)
+	}
+	if negate {
+		return notMatcher{m}, nil
+	}
+	return m, nil
+}
+
+// parseValues parses the right operand of a comparison, which can be a
+// parenthesized list when "list" is true.
+func (p *parser) parseValues(list bool) ([]token, error) {
+	if !list || p.peek().kind != tokLParen {
+		t, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return []token{t}, nil
+	}
+	p.next()
+	vals := []token{}
+	for {
+		t, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, t)
+		switch t := p.next(); t.kind {
+		case tokComma:
+		case tokRParen:
+			return vals, nil
+		default:
+			return nil, p.errorf(t, "unexpected %s, expected \",\" or \")\"", t)
+		}
+	}
+}
+
+func (p *parser) parseValue() (token, error) {
+	t := p.next()
+	if t.kind != tokWord && t.kind != tokString {
+		return t, p.errorf(t, "unexpected %s, expected a value", t)
+	}
+	return t, nil
+}
+
+// Evaluation.
+
+type matcher interface {
+	match(ONF) bool
+}
+
+type andMatcher struct{ left, right matcher }
+
+func (m andMatcher) match(f ONF) bool { return m.left.match(f) && m.right.match(f) }
+
+type orMatcher struct{ left, right matcher }
+
+func (m orMatcher) match(f ONF) bool { return m.left.match(f) || m.right.match(f) }
+
+type notMatcher struct{ m matcher }
+
+func (m notMatcher) match(f ONF) bool { return !m.m.match(f) }
+
+type matchFunc func(ONF) bool
+
+func (m matchFunc) match(f ONF) bool { return m(f) }
+
+// anyOf returns a matcher which is satisfied when at least one of "ms"
+// is.
+func anyOf(ms []matchFunc) matcher {
+	return matchFunc(func(f ONF) bool {
+		for _, m := range ms {
+			if m(f) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// queryField describes a field that can be used in queries.
+type queryField interface {
+	compile(op string, vals []token) (matcher, error)
+}
+
+var queryFields = map[string]queryField{
+	"cmd":     stringField{func(f ONF) string { return f.Cmd }, false},
+	"user":    stringField{func(f ONF) string { return f.User }, false},
+	"raw":     stringField{func(f ONF) string { return f.Raw }, false},
+	"family":  stringField{func(f ONF) string { return string(f.Family) }, true},
+	"proto":   stringField{func(f ONF) string { return f.Proto }, true},
+	"pid":     numberField(func(f ONF) (int64, bool) { return int64(f.Pid), true }),
+	"fd":      numberField(func(f ONF) (int64, bool) { return int64(f.Fd), f.Fd >= 0 }),
+	"uid":     numberField(func(f ONF) (int64, bool) { return int64(f.Uid), f.Uid >= 0 }),
+	"inode":   numberField(func(f ONF) (int64, bool) { return int64(f.Inode), f.Inode > 0 }),
+	"peerpid": numberField(func(f ONF) (int64, bool) { return int64(f.PeerPid), true }),
+	"sport":   numberField(func(f ONF) (int64, bool) { return addrPort(f.Src) }),
+	"dport":   numberField(func(f ONF) (int64, bool) { return addrPort(f.Dst) }),
+	"state":   stateField{},
+	"src":     addrField(func(f ONF) net.Addr { return f.Src }),
+	"dst":     addrField(func(f ONF) net.Addr { return f.Dst }),
+	"sclass":  classField(func(f ONF) net.Addr { return f.Src }),
+	"dclass":  classField(func(f ONF) net.Addr { return f.Dst }),
+}
+
+// QueryFields returns the sorted names of the fields that can be used in
+// queries.
+func QueryFields() []string {
+	acc := make([]string, 0, len(queryFields))
+	for k := range queryFields {
+		acc = append(acc, k)
+	}
+	sort.Strings(acc)
+	return acc
+}
+
+func valueError(t token, format string, args ...interface{}) error {
+	return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// compileRegexp handles the "~" and "!~" operators, which are supported
+// by every field, matching "s" against each value.
+func compileRegexp(op string, vals []token, s func(ONF) string) (matcher, error) {
+	rgx, err := regexp.Compile(vals[0].val)
+	if err != nil {
+		return nil, valueError(vals[0], "invalid regular expression: %v", err)
+	}
+	m := matchFunc(func(f ONF) bool { return rgx.MatchString(s(f)) })
+	if op == "!~" {
+		return notMatcher{m}, nil
+	}
+	return m, nil
+}
+
+// compileEquality builds the matchers of the "=", "!=" and "in"
+// operators out of "eq", which compiles a single value.
+func compileEquality(op string, vals []token, eq func(token) (matchFunc, error)) (matcher, error) {
+	ms := make([]matchFunc, 0, len(vals))
+	for _, v := range vals {
+		m, err := eq(v)
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+	switch op {
+	case "=", "in":
+		return anyOf(ms), nil
+	case "!=":
+		return notMatcher{anyOf(ms)}, nil
+	default:
+		return nil, fmt.Errorf("operator \"%s\" is not supported by this field", op)
+	}
+}
+
+type stringField struct {
+	get  func(ONF) string
+	fold bool // case insensitive equality
+}
+
+func (fd stringField) compile(op string, vals []token) (matcher, error) {
+	if op == "~" || op == "!~" {
+		return compileRegexp(op, vals, fd.get)
+	}
+	return compileEquality(op, vals, func(t token) (matchFunc, error) {
+		return func(f ONF) bool {
+			if fd.fold {
+				return strings.EqualFold(fd.get(f), t.val)
+			}
+			return fd.get(f) == t.val
+		}, nil
+	})
+}
+
+// numberField returns the value of a numeric field, and false when it is
+// not known.
+type numberField func(ONF) (int64, bool)
+
+func (fd numberField) compile(op string, vals []token) (matcher, error) {
+	str := func(f ONF) string {
+		if n, ok := fd(f); ok {
+			return strconv.FormatInt(n, 10)
+		}
+		return ""
+	}
+	if op == "~" || op == "!~" {
+		return compileRegexp(op, vals, str)
+	}
+	cmp := func(t token, f func(a, b int64) bool) (matchFunc, error) {
+		want, err := strconv.ParseInt(t.val, 10, 64)
+		if err != nil {
+			return nil, valueError(t, "expected a number, found %s", t)
+		}
+		return func(x ONF) bool {
+			n, ok := fd(x)
+			return ok && f(n, want)
+		}, nil
+	}
+	switch op {
+	case "<":
+		return cmp(vals[0], func(a, b int64) bool { return a < b })
+	case "<=":
+		return cmp(vals[0], func(a, b int64) bool { return a <= b })
+	case ">":
+		return cmp(vals[0], func(a, b int64) bool { return a > b })
+	case ">=":
+		return cmp(vals[0], func(a, b int64) bool { return a >= b })
+	}
+	return compileEquality(op, vals, func(t token) (matchFunc, error) {
+		// Ranges, e.g. 30000-32767, are accepted by equality too.
+		if i := strings.Index(t.val, "-"); i > 0 {
+			lo, hi := t, t
+			lo.val, hi.val = t.val[:i], t.val[i+1:]
+			ge, err := cmp(lo, func(a, b int64) bool { return a >= b })
+			if err != nil {
+				return nil, err
+			}
+			le, err := cmp(hi, func(a, b int64) bool { return a <= b })
+			if err != nil {
+				return nil, err
+			}
+			return func(x ONF) bool { return ge(x) && le(x) }, nil
+		}
+		return cmp(t, func(a, b int64) bool { return a == b })
+	})
+}
+
+type classField func(ONF) net.Addr
+
+func (fd classField) compile(op string, vals []token) (matcher, error) {
+	class := func(f ONF) AddrClass { return ClassOf(addrIP(fd(f))) }
+	if op == "~" || op == "!~" {
+		return compileRegexp(op, vals, func(f ONF) string { return class(f).String() })
+	}
+	return compileEquality(op, vals, func(t token) (matchFunc, error) {
+		c := ParseAddrClass(t.val)
+		if c == ClassUnknown && !strings.EqualFold(t.val, ClassUnknown.String()) {
+			return nil, valueError(t, "unknown address class %s", t)
+		}
+		return func(f ONF) bool { return class(f) == c }, nil
+	})
+}
+
+type stateField struct{}
+
+func (stateField) compile(op string, vals []token) (matcher, error) {
+	if op == "~" || op == "!~" {
+		return compileRegexp(op, vals, func(f ONF) string { return f.State.String() })
+	}
+	if op != "=" && op != "in" && op != "!=" {
+		return nil, fmt.Errorf("operator \"%s\" is not supported by this field", op)
+	}
+	m := stateMatcher{}
+	for _, t := range vals {
+		st := ParseState(t.val)
+		if st == StateUnknown && !strings.EqualFold(t.val, StateUnknown.String()) {
+			return nil, valueError(t, "unknown state %s", t)
+		}
+		m = append(m, st)
+	}
+	if op == "!=" {
+		return notMatcher{m}, nil
+	}
+	return m, nil
+}
+
+// stateMatcher is satisfied by the open network files in one of its
+// states. It is a distinct type so that States can find it.
+type stateMatcher []State
+
+func (m stateMatcher) match(f ONF) bool {
+	for _, v := range m {
+		if f.State == v {
+			return true
+		}
+	}
+	return false
+}
+
+// States returns the states an open network file must be in to satisfy
+// the query, or nil if the query does not constrain them, e.g. when
+// states are negated or compared against a regular expression. Backends
+// may use them as a hint, see Options.
+func (q *Query) States() []State {
+	return stateHint(q.root)
+}
+
+func stateHint(m matcher) []State {
+	switch m := m.(type) {
+	case stateMatcher:
+		return m
+	case andMatcher:
+		l, r := stateHint(m.left), stateHint(m.right)
+		if l == nil {
+			return r
+		}
+		if r == nil {
+			return l
+		}
+		acc := []State{}
+		for _, v := range l {
+			if stateMatcher(r).match(ONF{State: v}) {
+				acc = append(acc, v)
+			}
+		}
+		if len(acc) == 0 {
+			// Nothing can match, which is not worth a special case.
+			return l
+		}
+		return acc
+	case orMatcher:
+		l, r := stateHint(m.left), stateHint(m.right)
+		if l == nil || r == nil {
+			return nil
+		}
+		return append(append([]State{}, l...), r...)
+	default:
+		return nil
+	}
+}
+
+type addrField func(ONF) net.Addr
+
+func (fd addrField) compile(op string, vals []token) (matcher, error) {
+	str := func(f ONF) string {
+		if a := fd(f); a != nil {
+			return a.String()
+		}
+		return ""
+	}
+	if op == "~" || op == "!~" {
+		return compileRegexp(op, vals, str)
+	}
+	return compileEquality(op, vals, func(t token) (matchFunc, error) {
+		if strings.Contains(t.val, "/") && !strings.HasPrefix(t.val, "/") {
+			_, ipnet, err := net.ParseCIDR(t.val)
+			if err != nil {
+				return nil, valueError(t, "invalid CIDR %s: %v", t, err)
+			}
+			return func(f ONF) bool {
+				ip := addrIP(fd(f))
+				return ip != nil && ipnet.Contains(ip)
+			}, nil
+		}
+		if ip := net.ParseIP(strings.Trim(t.val, "[]")); ip != nil {
+			return func(f ONF) bool { return ip.Equal(addrIP(fd(f))) }, nil
+		}
+		return func(f ONF) bool { return str(f) == t.val }, nil
+	})
+}
+
+// addrIP returns the ip of "addr", or nil if it has none, e.g. unix
+// domain sockets or wildcard addresses.
+func addrIP(addr net.Addr) net.IP {
+	if addr == nil || addr.Network() == "unix" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	if i := strings.Index(host, "%"); i >= 0 {
+		host = host[:i]
+	}
+	return net.ParseIP(host)
+}
+
+// addrPort returns the port of "addr", and false if it has none.
+func addrPort(addr net.Addr) (int64, bool) {
+	if addr == nil || addr.Network() == "unix" {
+		return 0, false
+	}
+	_, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(port, 10, 64)
+	return n, err == nil
+}
@@ -0,0 +1,151 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/onf"
+)
+
+var querySet = []onf.ONF{
+	{
+		Raw: "Spotify 614 jecoz 247u IPv4 0x25c5bf09a393d583 0t0 TCP 192.168.0.61:58282->35.186.224.25:443 (ESTABLISHED)",
+		Cmd: "Spotify", Pid: 614, Fd: 247, User: "jecoz", Uid: 501, Family: onf.FamilyIPv4, Proto: "tcp",
+		State: onf.StateEstablished,
+		Src:   internal.NewAddr("tcp", "192.168.0.61:58282"),
+		Dst:   internal.NewAddr("tcp", "35.186.224.25:443"),
+	},
+	{
+		Cmd: "Spotify", Pid: 614, Fd: 250, User: "jecoz", Uid: 501, Family: onf.FamilyIPv4, Proto: "tcp",
+		State: onf.StateEstablished,
+		Src:   internal.NewAddr("tcp", "10.20.0.3:58290"),
+		Dst:   internal.NewAddr("tcp", "10.20.1.1:80"),
+	},
+	{
+		Cmd: "postgres", Pid: 676, Fd: 10, User: "postgres", Uid: 70, Family: onf.FamilyIPv6, Proto: "tcp",
+		State: onf.StateListen,
+		Src:   internal.NewAddr("tcp", "[::1]:5432"),
+		Dst:   internal.NewAddr("tcp", ""),
+	},
+	{
+		Cmd: "dockerd", Pid: 1100, Fd: -1, Uid: -1, Family: onf.FamilyUnix, Proto: "unix",
+		Src: onf.NewUnixAddr("/run/docker.sock"),
+		Dst: onf.NewUnixAddr(""),
+	},
+}
+
+func TestParseQuery(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		query string
+		pids  []int
+	}{
+		{`cmd ~ "Spotify" and dport in (80,443) and state = established and not dst in 10.0.0.0/8`, []int{614}},
+		{`cmd = Spotify`, []int{614, 614}},
+		{`cmd = spotify`, []int{}},
+		{`proto = TCP and state = LISTENING`, []int{676}},
+		{`pid >= 676 or fd = 247`, []int{614, 676, 1100}},
+		{`not (pid < 1000) and proto != tcp`, []int{1100}},
+		{`dst not in (10.0.0.0/8, 35.186.224.25)`, []int{676, 1100}},
+		{`src = ::1 or src = "/run/docker.sock"`, []int{676, 1100}},
+		{`src = 10.20.0.3:58290`, []int{614}},
+		{`sport > 50000 and dport = 80`, []int{614}},
+		{`family = ipv6 or family = UNIX or uid = 70`, []int{676, 1100}},
+		{`user !~ "^jec" and fd == 10`, []int{676}},
+		{`raw ~ ESTABLISHED`, []int{614}},
+		{`sport in (5000-6000, 58282) and sclass != loopback`, []int{614}},
+		{`dclass = public or dclass = private`, []int{614, 614}},
+	}
+	for _, v := range tt {
+		q, err := onf.ParseQuery(v.query)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", v.query, err)
+		}
+		pids := []int{}
+		for _, f := range q.Filter(querySet) {
+			pids = append(pids, f.Pid)
+		}
+		assert(t, v.pids, pids)
+	}
+}
+
+func TestParseQuery_SyntaxError(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		query string
+		pos   int
+		msg   string
+	}{
+		{`cmd ~ "x" and dprot = 1`, 14, `unknown field "dprot"`},
+		{`cmd`, 3, `expected an operator`},
+		{`pid = abc`, 6, `expected a number`},
+		{`state = estab`, 8, `unknown state "estab"`},
+		{`dst in (10.0.0.0/8,`, 19, `expected a value`},
+		{`dst in 10.0.0.0/33`, 7, `invalid CIDR`},
+		{`cmd ~ "(" `, 6, `invalid regular expression`},
+		{`cmd = "x`, 6, `unterminated string`},
+		{`cmd < x`, 4, `not supported`},
+		{`(pid = 1`, 8, `expected ")"`},
+		{`pid = 1 pid = 2`, 8, `expected "and", "or"`},
+		{`pid =< 1`, 4, `unknown operator "=<"`},
+		{`sport = 1-x`, 8, `expected a number`},
+		{`dclass = internet`, 9, `unknown address class "internet"`},
+	}
+	for _, v := range tt {
+		_, err := onf.ParseQuery(v.query)
+		se, ok := err.(*onf.SyntaxError)
+		if !ok {
+			t.Fatalf("%s: expected syntax error, found %v", v.query, err)
+		}
+		assert(t, v.query, se.Query)
+		assert(t, v.pos, se.Pos)
+		if !strings.Contains(se.Msg, v.msg) {
+			t.Fatalf("%s: expected message containing %q, found %q", v.query, v.msg, se.Msg)
+		}
+	}
+}
+
+func TestQuery_States(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		query  string
+		states []onf.State
+	}{
+		{`cmd = Spotify`, nil},
+		{`state = established`, []onf.State{onf.StateEstablished}},
+		{`cmd = Spotify and state in (listen, established)`, []onf.State{onf.StateListen, onf.StateEstablished}},
+		{`state in (listen, established) and (state = listen or pid = 1)`, []onf.State{onf.StateListen, onf.StateEstablished}},
+		{`state in (listen, established) and state = listen`, []onf.State{onf.StateListen}},
+		{`state = listen or state = time_wait`, []onf.State{onf.StateListen, onf.StateTimeWait}},
+		{`state = listen or pid = 1`, nil},
+		{`state != listen`, nil},
+		{`not state = listen`, nil},
+		{`state ~ LISTEN`, nil},
+	}
+	for _, v := range tt {
+		q, err := onf.ParseQuery(v.query)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", v.query, err)
+		}
+		assert(t, v.states, q.States())
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// +build freebsd netbsd openbsd dragonfly
+
+package onf
+
+import (
+	"context"
+	"log"
+)
+
+// fetchAll prefers sockstat, as lsof is not part of the base system.
+func fetchAll(ctx context.Context, opts Options) ([]ONF, error) {
+	set, err := fetchSockstat(ctx, opts)
+	if err == nil {
+		return set, nil
+	}
+	log.Printf("unable to run sockstat, falling back to lsof: %v", err)
+	return fetchLsof(ctx, opts)
+}
+
+func fetchProcesses(ctx context.Context, opts Options) (ProcessTable, error) {
+	return fetchPs(ctx, opts)
+}
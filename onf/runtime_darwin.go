@@ -0,0 +1,50 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"context"
+	"log"
+)
+
+// fetchAll prefers netstat, which reports the pid owning each socket
+// without requiring elevated privileges and is faster than lsof. lsof is
+// used for unix domain sockets, and on the macOS versions whose netstat
+// does not print pids.
+func fetchAll(ctx context.Context, opts Options) ([]ONF, error) {
+	if opts.Unix {
+		return fetchLsof(ctx, opts)
+	}
+	set, err := fetchNetstatDarwin(ctx, opts)
+	if err != nil {
+		log.Printf("unable to run netstat, falling back to lsof: %v", err)
+		return fetchLsof(ctx, opts)
+	}
+	if len(set) == 0 {
+		return set, nil
+	}
+	for _, v := range set {
+		if v.Attributed() {
+			return set, nil
+		}
+	}
+	log.Printf("netstat does not report pids, falling back to lsof")
+	return fetchLsof(ctx, opts)
+}
+
+func fetchProcesses(ctx context.Context, opts Options) (ProcessTable, error) {
+	return fetchPs(ctx, opts)
+}
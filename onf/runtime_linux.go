@@ -0,0 +1,57 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"context"
+	"log"
+)
+
+// fetchAll asks the kernel for the list of sockets using sock_diag. When
+// it is not available, the socket tables exposed under /proc/net are
+// read instead, falling back to ss and then to lsof as last resort.
+func fetchAll(ctx context.Context, opts Options) ([]ONF, error) {
+	set, err := fetchNetlink(ctx, opts)
+	if err == nil {
+		return set, nil
+	}
+	if ctx.Err() != nil {
+		return []ONF{}, err
+	}
+	log.Printf("unable to query sock_diag, falling back to /proc/net: %v", err)
+	set, err = fetchProcfs(ctx, opts)
+	if err == nil {
+		return set, nil
+	}
+	log.Printf("unable to read socket tables, falling back to ss: %v", err)
+	set, err = fetchSs(ctx, opts)
+	if err == nil {
+		return set, nil
+	}
+	log.Printf("unable to run ss, falling back to lsof: %v", err)
+	return fetchLsof(ctx, opts)
+}
+
+// fetchProcesses reads the process table from /proc, falling back to ps
+// when it is not available.
+func fetchProcesses(ctx context.Context, opts Options) (ProcessTable, error) {
+	t, err := fetchProcStat(ctx, opts)
+	if err == nil {
+		return t, nil
+	}
+	log.Printf("unable to read process table, falling back to ps: %v", err)
+	return fetchPs(ctx, opts)
+}
@@ -13,31 +13,16 @@
 // You should have received a copy of the GNU Affero General Public License
 // along with this program. If not, see <http://www.gnu.org/licenses/>.
 
-// +build !windows
+// +build !windows,!linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
 
 package onf
 
-import (
-	"time"
+import "context"
 
-	"github.com/jecoz/lsaddr/lsof"
-)
+func fetchAll(ctx context.Context, opts Options) ([]ONF, error) {
+	return fetchLsof(ctx, opts)
+}
 
-func fetchAll() ([]ONF, error) {
-	set, err := lsof.Run()
-	if err != nil {
-		return []ONF{}, err
-	}
-	mapped := make([]ONF, len(set))
-	for i, v := range set {
-		mapped[i] = ONF{
-			Raw:       v.Raw,
-			Cmd:       v.Command,
-			Pid:       v.Pid,
-			Src:       v.SrcAddr,
-			Dst:       v.DstAddr,
-			CreatedAt: time.Now(),
-		}
-	}
-	return mapped, nil
+func fetchProcesses(ctx context.Context, opts Options) (ProcessTable, error) {
+	return fetchPs(ctx, opts)
 }
@@ -18,25 +18,22 @@
 package onf
 
 import (
-	"time"
-
-	"github.com/jecoz/lsaddr/netstat"
+	"context"
+	"fmt"
+	"log"
 )
 
-func fetchAll() ([]ONF, error) {
-	set, err := netstat.Run()
-	if err != nil {
-		return []ONF{}, err
-	}
-	mapped := make([]ONF, len(set))
-	for i, v := range set {
-		mapped[i] = ONF{
-			Raw:       v.Raw,
-			Pid:       v.Pid,
-			Src:       v.SrcAddr,
-			Dst:       v.DstAddr,
-			CreatedAt: time.Now(),
-		}
+// fetchAll collects connections using PowerShell, whose output does not
+// depend on the locale, falling back to netstat when it is not available.
+func fetchAll(ctx context.Context, opts Options) ([]ONF, error) {
+	set, err := fetchPowerShell(ctx, opts)
+	if err == nil {
+		return set, nil
 	}
-	return mapped, nil
+	log.Printf("unable to query PowerShell, falling back to netstat: %v", err)
+	return fetchNetstat(ctx, opts)
+}
+
+func fetchProcesses(ctx context.Context, opts Options) (ProcessTable, error) {
+	return ProcessTable{}, fmt.Errorf("process tree is not supported on windows")
 }
@@ -0,0 +1,72 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jecoz/lsaddr/sockstat"
+)
+
+func init() {
+	Register("sockstat", FetcherFunc(fetchSockstat))
+}
+
+// fetchSockstat runs sockstat, which reports the owner of each socket by
+// itself. Unix domain sockets are not collected.
+func fetchSockstat(ctx context.Context, opts Options) ([]ONF, error) {
+	if opts.Unix {
+		log.Printf("unix domain sockets are not collected using sockstat")
+	}
+	set, err := sockstat.Run(ctx, opts.Options)
+	if err != nil {
+		return []ONF{}, err
+	}
+	return mapSockstat(set), nil
+}
+
+// mapSockstat maps the sockets reported by sockstat. Unless the state
+// was requested with -s, tcp sockets without a peer are assumed to be
+// listening, as only listening and connected sockets are listed.
+func mapSockstat(set []sockstat.Socket) []ONF {
+	mapped := make([]ONF, len(set))
+	for i, v := range set {
+		f := ONF{
+			Raw:       v.Raw,
+			Cmd:       v.Command,
+			Pid:       v.Pid,
+			Fd:        v.Fd,
+			User:      v.User,
+			Uid:       -1,
+			Family:    ipFamily(v.IPv6),
+			Proto:     v.Network,
+			Src:       v.SrcAddr,
+			Dst:       v.DstAddr,
+			CreatedAt: time.Now(),
+		}
+		switch {
+		case v.Network != "tcp":
+		case v.State != "":
+			f.State = ParseState(v.State)
+		case v.DstAddr.String() == "":
+			f.State = StateListen
+		}
+		mapped[i] = f
+	}
+	return mapped
+}
@@ -0,0 +1,95 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"context"
+	"log"
+	"net"
+	"time"
+
+	"github.com/jecoz/lsaddr/ss"
+)
+
+func init() {
+	Register("ss", FetcherFunc(fetchSs))
+}
+
+// fetchSs runs ss, which reports the owners of each socket by itself.
+// Unix domain sockets are not collected.
+func fetchSs(ctx context.Context, opts Options) ([]ONF, error) {
+	if opts.Unix {
+		log.Printf("unix domain sockets are not collected using ss")
+	}
+	set, err := ss.Run(ctx, opts.Options)
+	if err != nil {
+		return []ONF{}, err
+	}
+	return resolveUsers(mapSs(set)), nil
+}
+
+// mapSs returns a copy of each socket for each of its owners. Sockets
+// without owners, e.g. because of missing privileges, are kept. Users
+// are not resolved, see resolveUsers.
+func mapSs(set []ss.Socket) []ONF {
+	mapped := make([]ONF, 0, len(set))
+	for _, v := range set {
+		f := ONF{
+			Raw:       v.Raw,
+			Fd:        -1,
+			Uid:       v.Uid,
+			Family:    ssFamily(v),
+			Proto:     v.Network,
+			Inode:     v.Inode,
+			Src:       v.SrcAddr,
+			Dst:       v.DstAddr,
+			CreatedAt: time.Now(),
+		}
+		if v.Network == "tcp" {
+			f.State = ParseSsState(v.State)
+		}
+		if len(v.Owners) == 0 {
+			mapped = append(mapped, f)
+			continue
+		}
+		for _, o := range v.Owners {
+			f.Cmd = o.Cmd
+			f.Pid = o.Pid
+			f.Fd = o.Fd
+			mapped = append(mapped, f)
+		}
+	}
+	return mapped
+}
+
+// ssFamily returns the family of "s", taken from its peer when the source
+// has no ip. ss prints the wildcard address of IPv6 sockets that accept
+// IPv4 connections too as "*".
+func ssFamily(s ss.Socket) Family {
+	if f := familyOf(s.SrcAddr); f != FamilyUnknown {
+		return f
+	}
+	if f := familyOf(s.DstAddr); f != FamilyUnknown {
+		return f
+	}
+	if s.SrcAddr == nil {
+		return FamilyUnknown
+	}
+	if host, _, err := net.SplitHostPort(s.SrcAddr.String()); err == nil && host == "*" {
+		return FamilyIPv6
+	}
+	return FamilyUnknown
+}
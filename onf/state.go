@@ -0,0 +1,252 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jecoz/lsaddr/procfs"
+)
+
+// State is the normalized state of a connection. Backends describe
+// states with different vocabularies, which are all mapped to the same
+// set of values.
+type State int
+
+// Supported states. They follow the TCP state machine, plus Bound, used
+// by windows for sockets that are bound but neither listening nor
+// connected.
+const (
+	StateUnknown State = iota
+	StateEstablished
+	StateSynSent
+	StateSynRecv
+	StateFinWait1
+	StateFinWait2
+	StateTimeWait
+	StateClosed
+	StateCloseWait
+	StateLastAck
+	StateListen
+	StateClosing
+	StateBound
+)
+
+var stateNames = [...]string{
+	StateUnknown:     "UNKNOWN",
+	StateEstablished: "ESTABLISHED",
+	StateSynSent:     "SYN_SENT",
+	StateSynRecv:     "SYN_RECV",
+	StateFinWait1:    "FIN_WAIT1",
+	StateFinWait2:    "FIN_WAIT2",
+	StateTimeWait:    "TIME_WAIT",
+	StateClosed:      "CLOSED",
+	StateCloseWait:   "CLOSE_WAIT",
+	StateLastAck:     "LAST_ACK",
+	StateListen:      "LISTEN",
+	StateClosing:     "CLOSING",
+	StateBound:       "BOUND",
+}
+
+func (s State) String() string {
+	if s < 0 || int(s) >= len(stateNames) {
+		return stateNames[StateUnknown]
+	}
+	return stateNames[s]
+}
+
+// IsListening reports whether the socket is waiting for connections.
+func (s State) IsListening() bool {
+	return s == StateListen
+}
+
+// IsConnected reports whether the connection is established, i.e. data
+// can flow in both directions.
+func (s State) IsConnected() bool {
+	return s == StateEstablished
+}
+
+// IsClosing reports whether the connection is being torn down, by either
+// side. Closed connections are not closing anymore.
+func (s State) IsClosing() bool {
+	switch s {
+	case StateFinWait1, StateFinWait2, StateTimeWait, StateCloseWait, StateLastAck, StateClosing:
+		return true
+	default:
+		return false
+	}
+}
+
+// stateAliases maps the names used by the different backends to their
+// normalized state, when they differ from the State names.
+var stateAliases = map[string]State{
+	"LISTENING":    StateListen,
+	"SYN_RECEIVED": StateSynRecv,
+	"SYN_RCVD":     StateSynRecv, // macOS and the BSDs
+	"FIN_WAIT_1":   StateFinWait1,
+	"FIN_WAIT_2":   StateFinWait2,
+	"CLOSE":        StateClosed,
+	"IDLE":         StateClosed,
+	"DELETE_TCB":   StateClosed,
+}
+
+// netstatLocalizations maps the states printed by localized versions of
+// windows' netstat. Accented names are listed in their ascii form too, as
+// the console code page may not survive the trip.
+var netstatLocalizations = map[string]State{
+	// German
+	"ABHÖREN":           StateListen,
+	"ABHOEREN":          StateListen,
+	"ABHOREN":           StateListen,
+	"HERGESTELLT":       StateEstablished,
+	"SYN_GESENDET":      StateSynSent,
+	"SYN_EMPFANGEN":     StateSynRecv,
+	"FIN_WARTEN_1":      StateFinWait1,
+	"FIN_WARTEN_2":      StateFinWait2,
+	"WARTEND":           StateTimeWait,
+	"SCHLIESSEN_WARTEN": StateCloseWait,
+	"LETZTE_BEST":       StateLastAck,
+	"SCHLIESSEND":       StateClosing,
+	"GESCHLOSSEN":       StateClosed,
+	"GEBUNDEN":          StateBound,
+	// French
+	"ÉCOUTE":  StateListen,
+	"ECOUTE":  StateListen,
+	"ÉTABLI":  StateEstablished,
+	"ETABLI":  StateEstablished,
+	"ÉTABLIE": StateEstablished,
+	"ETABLIE": StateEstablished,
+	"FERMÉ":   StateClosed,
+	"FERME":   StateClosed,
+	// Spanish
+	"ESCUCHANDO":  StateListen,
+	"ESTABLECIDO": StateEstablished,
+	"CERRADO":     StateClosed,
+	// Italian
+	"IN_ASCOLTO": StateListen,
+	"STABILITO":  StateEstablished,
+	"STABILITA":  StateEstablished,
+	"CHIUSO":     StateClosed,
+	// Portuguese
+	"ESCUTANDO":    StateListen,
+	"ESTABELECIDA": StateEstablished,
+	"ESTABELECIDO": StateEstablished,
+	"FECHADO":      StateClosed,
+	// Polish
+	"NASŁUCHIWANIE": StateListen,
+	"NASLUCHIWANIE": StateListen,
+	"USTANOWIONO":   StateEstablished,
+	"ZAMKNIĘTE":     StateClosed,
+	// Russian
+	"ПРОСЛУШИВАНИЕ": StateListen,
+	"УСТАНОВЛЕНО":   StateEstablished,
+}
+
+// normalizeState prepares "s" for a lookup in the state tables.
+func normalizeState(s string) string {
+	s = strings.ToUpper(strings.Trim(strings.TrimSpace(s), "()"))
+	s = strings.Join(strings.Fields(s), "_")
+	return strings.Replace(s, "-", "_", -1)
+}
+
+func lookupState(s string, tables ...map[string]State) State {
+	if s == "" {
+		return StateUnknown
+	}
+	for i, v := range stateNames {
+		if v == s {
+			return State(i)
+		}
+	}
+	for _, t := range tables {
+		if st, ok := t[s]; ok {
+			return st
+		}
+	}
+	return StateUnknown
+}
+
+// ParseState returns the state named "s", accepting the names used by
+// every backend, e.g. netstat's "LISTENING" or "FIN_WAIT_1". The states
+// printed by the most common localized windows versions are recognised
+// too, e.g. "ABHÖREN" or "IN ASCOLTO". Unrecognised states are returned
+// as StateUnknown.
+func ParseState(s string) State {
+	return lookupState(normalizeState(s), stateAliases, netstatLocalizations)
+}
+
+// ParseLsofState returns the state reported by lsof, which wraps it in
+// parentheses in its default output, e.g. "(ESTABLISHED)", and prints it
+// bare in its field output. Unix sockets states, e.g. "CONNECTED", are
+// not recognised.
+func ParseLsofState(s string) State {
+	return lookupState(normalizeState(s), stateAliases)
+}
+
+// ParseSsState returns the state reported by ss, which abbreviates some
+// of them, e.g. "ESTAB", "SYN-SENT" or "UNCONN".
+func ParseSsState(s string) State {
+	return lookupState(normalizeState(s), ssStates, stateAliases)
+}
+
+// ssStates maps the abbreviations used by ss, see sstate_name in
+// iproute2's misc/ss.c.
+var ssStates = map[string]State{
+	"ESTAB":  StateEstablished,
+	"UNCONN": StateClosed,
+}
+
+// ParseKernelState returns the state represented by the hexadecimal code
+// used by the socket tables in /proc/net, e.g. "0A" for LISTEN.
+func ParseKernelState(s string) State {
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 16, 8)
+	if err != nil {
+		return StateUnknown
+	}
+	return stateFromKernel(procfs.State(n))
+}
+
+// kernelStates maps the states used by the linux kernel, see
+// include/net/tcp_states.h.
+var kernelStates = map[procfs.State]State{
+	procfs.Established: StateEstablished,
+	procfs.SynSent:     StateSynSent,
+	procfs.SynRecv:     StateSynRecv,
+	procfs.FinWait1:    StateFinWait1,
+	procfs.FinWait2:    StateFinWait2,
+	procfs.TimeWait:    StateTimeWait,
+	procfs.Close:       StateClosed,
+	procfs.CloseWait:   StateCloseWait,
+	procfs.LastAck:     StateLastAck,
+	procfs.Listen:      StateListen,
+	procfs.Closing:     StateClosing,
+	procfs.NewSynRecv:  StateSynRecv,
+}
+
+func stateFromKernel(s procfs.State) State {
+	return kernelStates[s]
+}
+
+// socketState returns the state of a socket of "network" as reported by
+// the kernel. The kernel uses tcp states for udp sockets too, i.e. CLOSE
+// for unconnected ones, which would be misleading.
+func socketState(network string, s procfs.State) State {
+	if network != "tcp" {
+		return StateUnknown
+	}
+	return stateFromKernel(s)
+}
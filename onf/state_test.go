@@ -0,0 +1,130 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf_test
+
+import (
+	"testing"
+
+	"github.com/jecoz/lsaddr/onf"
+)
+
+func TestParseLsofState(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		in  string
+		out onf.State
+	}{
+		{"(ESTABLISHED)", onf.StateEstablished},
+		{"LISTEN", onf.StateListen},
+		{"(SYN_RECEIVED)", onf.StateSynRecv},
+		{"FIN_WAIT_2", onf.StateFinWait2},
+		{"(CLOSE_WAIT)", onf.StateCloseWait},
+		{"IDLE", onf.StateClosed},
+		{"", onf.StateUnknown},
+		{"CONNECTED", onf.StateUnknown},
+	}
+	for _, v := range tt {
+		assert(t, v.out, onf.ParseLsofState(v.in))
+		assert(t, v.out, onf.ParseState(v.in))
+	}
+}
+
+func TestParseState_Netstat(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		in  string
+		out onf.State
+	}{
+		{"LISTENING", onf.StateListen},
+		{"ESTABLISHED", onf.StateEstablished},
+		{"TIME_WAIT", onf.StateTimeWait},
+		{"BOUND", onf.StateBound},
+		{"ABHÖREN", onf.StateListen},
+		{"HERGESTELLT", onf.StateEstablished},
+		{"SCHLIESSEN_WARTEN", onf.StateCloseWait},
+		{"ÉCOUTE", onf.StateListen},
+		{"ESCUCHANDO", onf.StateListen},
+		{"IN ASCOLTO", onf.StateListen},
+		{"abhören", onf.StateListen},
+		{"BOGUS", onf.StateUnknown},
+	}
+	for _, v := range tt {
+		assert(t, v.out, onf.ParseState(v.in))
+	}
+}
+
+func TestParseSsState(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		in  string
+		out onf.State
+	}{
+		{"ESTAB", onf.StateEstablished},
+		{"LISTEN", onf.StateListen},
+		{"SYN-SENT", onf.StateSynSent},
+		{"SYN-RECV", onf.StateSynRecv},
+		{"FIN-WAIT-2", onf.StateFinWait2},
+		{"TIME-WAIT", onf.StateTimeWait},
+		{"UNCONN", onf.StateClosed},
+		{"ESTABLISHED", onf.StateEstablished},
+	}
+	for _, v := range tt {
+		assert(t, v.out, onf.ParseSsState(v.in))
+	}
+}
+
+func TestParseKernelState(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		in  string
+		out onf.State
+	}{
+		{"01", onf.StateEstablished},
+		{"0A", onf.StateListen},
+		{"0a", onf.StateListen},
+		{"06", onf.StateTimeWait},
+		{"07", onf.StateClosed},
+		{"0C", onf.StateSynRecv},
+		{"FF", onf.StateUnknown},
+		{"zz", onf.StateUnknown},
+	}
+	for _, v := range tt {
+		assert(t, v.out, onf.ParseKernelState(v.in))
+	}
+}
+
+func TestState_Predicates(t *testing.T) {
+	t.Parallel()
+
+	assert(t, true, onf.StateListen.IsListening())
+	assert(t, false, onf.StateBound.IsListening())
+	assert(t, true, onf.StateEstablished.IsConnected())
+	assert(t, false, onf.StateSynSent.IsConnected())
+	for _, v := range []onf.State{onf.StateFinWait1, onf.StateFinWait2, onf.StateTimeWait, onf.StateCloseWait, onf.StateLastAck, onf.StateClosing} {
+		if !v.IsClosing() {
+			t.Fatalf("%v: expected closing state", v)
+		}
+	}
+	for _, v := range []onf.State{onf.StateUnknown, onf.StateEstablished, onf.StateListen, onf.StateClosed} {
+		if v.IsClosing() {
+			t.Fatalf("%v: unexpected closing state", v)
+		}
+	}
+}
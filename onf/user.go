@@ -0,0 +1,54 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package onf
+
+import (
+	"os/user"
+	"strconv"
+	"sync"
+)
+
+var usernames sync.Map // uid -> name
+
+// lookupUser returns the name of the user identified by "uid", or the
+// uid itself when it cannot be resolved. Results are cached, as the same
+// few users own most sockets.
+func lookupUser(uid int) string {
+	if uid < 0 {
+		return ""
+	}
+	if name, ok := usernames.Load(uid); ok {
+		return name.(string)
+	}
+	name := strconv.Itoa(uid)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	usernames.Store(uid, name)
+	return name
+}
+
+// resolveUsers fills the user name of each open network file of "set"
+// that has none. It is meant for sockets collected on this host only, as
+// the uids of snapshots parsed from files may belong to other hosts.
+func resolveUsers(set []ONF) []ONF {
+	for i, v := range set {
+		if v.User == "" {
+			set[i].User = lookupUser(v.Uid)
+		}
+	}
+	return set
+}
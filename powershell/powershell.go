@@ -0,0 +1,293 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package powershell collects connections on windows using the
+// Get-NetTCPConnection and Get-NetUDPEndpoint cmdlets, whose JSON output
+// does not change across locales as netstat's does.
+package powershell
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/tool"
+)
+
+// Script collects both tcp connections and udp endpoints with a single
+// PowerShell invocation, as starting PowerShell is slow.
+const Script = `@{` +
+	`tcp=@(Get-NetTCPConnection | Select-Object LocalAddress,LocalPort,RemoteAddress,RemotePort,State,OwningProcess,CreationTime);` +
+	`udp=@(Get-NetUDPEndpoint | Select-Object LocalAddress,LocalPort,OwningProcess,CreationTime)` +
+	`} | ConvertTo-Json -Depth 3`
+
+// Connection is a tcp connection or udp endpoint.
+type Connection struct {
+	Raw           string // json encoded entry
+	Proto         string // tcp or udp
+	SrcAddr       net.Addr
+	DstAddr       net.Addr
+	State         string // e.g. LISTEN or SYN_RECEIVED, empty for udp
+	OwningProcess int
+	CreationTime  time.Time // zero if unknown
+}
+
+// Run executes Script through ``powershell -NoProfile -NonInteractive'',
+// configured with "opts", and parses its output with ``ParseOutput''.
+// Execution errors are of type *tool.Error.
+func Run(ctx context.Context, opts tool.Options) ([]Connection, error) {
+	out, err := tool.Run(ctx, opts, "powershell", "-NoProfile", "-NonInteractive", "-Command", Script)
+	if err != nil {
+		return []Connection{}, fmt.Errorf("unable to run powershell: %w", err)
+	}
+	return ParseOutput(bytes.NewBuffer(out))
+}
+
+// entry is a single object produced by ConvertTo-Json. UDP endpoints do
+// not have the remote address and state properties.
+type entry struct {
+	LocalAddress  string
+	LocalPort     int
+	RemoteAddress string
+	RemotePort    int
+	State         *state
+	OwningProcess int
+	CreationTime  psTime
+	raw           string
+}
+
+// ParseOutput expects "r" to contain either the output of Script, i.e.
+// an object with "tcp" and "udp" lists, or the output of
+// ``Get-NetTCPConnection | ConvertTo-Json'' or
+// ``Get-NetUDPEndpoint | ConvertTo-Json'', which is a list of entries, or
+// a single entry when only one is found. Entries without remote address
+// and state are udp endpoints. Empty outputs produce empty lists.
+func ParseOutput(r io.Reader) ([]Connection, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return []Connection{}, err
+	}
+	b = bytes.TrimSpace(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf")))
+	if len(b) == 0 {
+		return []Connection{}, nil
+	}
+
+	if b[0] == '{' {
+		var combined struct {
+			TCP *entries `json:"tcp"`
+			UDP *entries `json:"udp"`
+		}
+		if err := json.Unmarshal(b, &combined); err != nil {
+			return []Connection{}, fmt.Errorf("unable to decode output: %w", err)
+		}
+		if combined.TCP != nil || combined.UDP != nil {
+			set := []Connection{}
+			for _, v := range []*entries{combined.TCP, combined.UDP} {
+				if v == nil {
+					continue
+				}
+				acc, err := v.connections()
+				if err != nil {
+					return []Connection{}, err
+				}
+				set = append(set, acc...)
+			}
+			return set, nil
+		}
+	}
+	var es entries
+	if err := json.Unmarshal(b, &es); err != nil {
+		return []Connection{}, fmt.Errorf("unable to decode output: %w", err)
+	}
+	return es.connections()
+}
+
+// entries decodes both a list of entries and a single one.
+type entries []entry
+
+func (es *entries) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	raws := []json.RawMessage{}
+	if len(b) > 0 && b[0] == '{' {
+		raws = append(raws, b)
+	} else if err := json.Unmarshal(b, &raws); err != nil {
+		return err
+	}
+	acc := make(entries, len(raws))
+	for i, v := range raws {
+		if err := json.Unmarshal(v, &acc[i]); err != nil {
+			return err
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, v); err != nil {
+			return err
+		}
+		acc[i].raw = compact.String()
+	}
+	*es = acc
+	return nil
+}
+
+func (es entries) connections() ([]Connection, error) {
+	set := make([]Connection, 0, len(es))
+	for _, v := range es {
+		c, err := v.connection()
+		if err != nil {
+			return []Connection{}, err
+		}
+		set = append(set, *c)
+	}
+	return set, nil
+}
+
+func (e entry) connection() (*Connection, error) {
+	c := &Connection{
+		Raw:           e.raw,
+		Proto:         "udp",
+		OwningProcess: e.OwningProcess,
+		CreationTime:  time.Time(e.CreationTime),
+	}
+	if e.State != nil || e.RemoteAddress != "" {
+		c.Proto = "tcp"
+	}
+	if e.State != nil {
+		c.State = string(*e.State)
+	}
+	if net.ParseIP(e.LocalAddress) == nil {
+		return nil, fmt.Errorf("invalid local address %s", e.LocalAddress)
+	}
+	c.SrcAddr = newAddr(c.Proto, e.LocalAddress, e.LocalPort)
+	c.DstAddr = internal.NewAddr(c.Proto, "")
+	if ip := net.ParseIP(e.RemoteAddress); ip != nil && !(ip.IsUnspecified() && e.RemotePort == 0) {
+		c.DstAddr = newAddr(c.Proto, e.RemoteAddress, e.RemotePort)
+	}
+	return c, nil
+}
+
+func newAddr(network, host string, port int) net.Addr {
+	return internal.NewAddr(network, net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+// tcpStates maps the values of the MSFT_NetTCPConnection State enum to
+// the names used by netstat.
+var tcpStates = map[int]string{
+	1:   "CLOSED",
+	2:   "LISTEN",
+	3:   "SYN_SENT",
+	4:   "SYN_RECEIVED",
+	5:   "ESTABLISHED",
+	6:   "FIN_WAIT_1",
+	7:   "FIN_WAIT_2",
+	8:   "CLOSE_WAIT",
+	9:   "CLOSING",
+	10:  "LAST_ACK",
+	11:  "TIME_WAIT",
+	12:  "DELETE_TCB",
+	100: "BOUND",
+}
+
+// state decodes the State enum, which ConvertTo-Json encodes as a number
+// unless -EnumsAsStrings is used, e.g. 2 or "Listen".
+type state string
+
+func (s *state) UnmarshalJSON(b []byte) error {
+	var n int
+	if err := json.Unmarshal(b, &n); err == nil {
+		*s = state(tcpStates[n])
+		return nil
+	}
+	var name string
+	if err := json.Unmarshal(b, &name); err != nil {
+		return fmt.Errorf("invalid state %s", b)
+	}
+	for _, v := range tcpStates {
+		if strings.EqualFold(strings.Replace(v, "_", "", -1), name) {
+			*s = state(v)
+			return nil
+		}
+	}
+	*s = state(strings.ToUpper(name))
+	return nil
+}
+
+// psTime decodes the dates produced by ConvertTo-Json. Windows PowerShell
+// encodes them as "/Date(1571213456789)/", sometimes wrapped in an
+// object holding it in its "value" property, while PowerShell Core uses
+// ISO 8601.
+type psTime time.Time
+
+func (t *psTime) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || string(b) == "null" {
+		return nil
+	}
+	if b[0] == '{' {
+		var wrapped struct {
+			Value *psTime `json:"value"`
+		}
+		if err := json.Unmarshal(b, &wrapped); err != nil {
+			return err
+		}
+		if wrapped.Value != nil {
+			*t = *wrapped.Value
+		}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("invalid date %s", b)
+	}
+	parsed, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*t = psTime(parsed)
+	return nil
+}
+
+// ParseDate parses a date as encoded by ConvertTo-Json, either in the
+// "/Date(<unix milliseconds>[+-offset])/" or in the ISO 8601 format.
+func ParseDate(s string) (time.Time, error) {
+	if strings.HasPrefix(s, "/Date(") && strings.HasSuffix(s, ")/") {
+		raw := strings.TrimSuffix(strings.TrimPrefix(s, "/Date("), ")/")
+		if raw == "" {
+			return time.Time{}, fmt.Errorf("invalid date %s: missing milliseconds", s)
+		}
+		// The offset, if any, does not change the instant.
+		if i := strings.IndexAny(raw[1:], "+-"); i >= 0 {
+			raw = raw[:i+1]
+		}
+		ms, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %s: %w", s, err)
+		}
+		return time.Unix(0, ms*int64(time.Millisecond)), nil
+	}
+	// Dates without offset are in the local time of the machine.
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %s", s)
+}
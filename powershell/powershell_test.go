@@ -0,0 +1,185 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package powershell
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// tcpExample is the output of Windows PowerShell 5.1, where enums are
+// numbers and dates are in the "/Date(...)/" format.
+const tcpExample = `[
+    {
+        "LocalAddress":  "0.0.0.0",
+        "LocalPort":  135,
+        "RemoteAddress":  "0.0.0.0",
+        "RemotePort":  0,
+        "State":  2,
+        "OwningProcess":  748,
+        "CreationTime":  "\/Date(1571213456789)\/"
+    },
+    {
+        "LocalAddress":  "192.168.1.5",
+        "LocalPort":  50123,
+        "RemoteAddress":  "142.250.180.78",
+        "RemotePort":  443,
+        "State":  5,
+        "OwningProcess":  4120,
+        "CreationTime":  {
+                             "value":  "\/Date(1571213500000+0200)\/",
+                             "DisplayHint":  2,
+                             "DateTime":  "Wednesday, October 16, 2019 10:11:40 AM"
+                         }
+    },
+    {
+        "LocalAddress":  "::1",
+        "LocalPort":  49671,
+        "RemoteAddress":  "::1",
+        "RemotePort":  49670,
+        "State":  11,
+        "OwningProcess":  0,
+        "CreationTime":  null
+    }
+]`
+
+func TestParseOutput_TCP(t *testing.T) {
+	t.Parallel()
+
+	set, err := ParseOutput(bytes.NewBufferString(tcpExample))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 3 {
+		t.Fatalf("Unexpected set length: wanted 3, found %d: %v", len(set), set)
+	}
+	c := set[0]
+	assert(t, "tcp", c.Proto)
+	assert(t, "0.0.0.0:135", c.SrcAddr.String())
+	assert(t, "", c.DstAddr.String())
+	assert(t, "LISTEN", c.State)
+	assert(t, 748, c.OwningProcess)
+	assert(t, int64(1571213456789), c.CreationTime.UnixNano()/int64(time.Millisecond))
+	assert(t, `{"LocalAddress":"0.0.0.0","LocalPort":135,"RemoteAddress":"0.0.0.0","RemotePort":0,"State":2,"OwningProcess":748,"CreationTime":"\/Date(1571213456789)\/"}`, c.Raw)
+
+	c = set[1]
+	assert(t, "142.250.180.78:443", c.DstAddr.String())
+	assert(t, "ESTABLISHED", c.State)
+	assert(t, int64(1571213500), c.CreationTime.Unix())
+
+	c = set[2]
+	assert(t, "[::1]:49671", c.SrcAddr.String())
+	assert(t, "[::1]:49670", c.DstAddr.String())
+	assert(t, "TIME_WAIT", c.State)
+	assert(t, true, c.CreationTime.IsZero())
+}
+
+// combinedExample is the output of Script on PowerShell Core, with
+// enums as strings and a single udp endpoint.
+const combinedExample = `{
+  "udp": {
+    "LocalAddress": "0.0.0.0",
+    "LocalPort": 5353,
+    "OwningProcess": 2212,
+    "CreationTime": "2019-10-16T10:10:56.789+02:00"
+  },
+  "tcp": [
+    {
+      "LocalAddress": "0.0.0.0",
+      "LocalPort": 445,
+      "RemoteAddress": "0.0.0.0",
+      "RemotePort": 0,
+      "State": "Listen",
+      "OwningProcess": 4,
+      "CreationTime": "2019-10-16T08:00:00.123"
+    },
+    {
+      "LocalAddress": "10.0.0.2",
+      "LocalPort": 50200,
+      "RemoteAddress": "20.42.65.92",
+      "RemotePort": 443,
+      "State": "SynReceived",
+      "OwningProcess": 2212,
+      "CreationTime": "2019-10-16T10:10:56Z"
+    }
+  ]
+}`
+
+func TestParseOutput_Combined(t *testing.T) {
+	t.Parallel()
+
+	set, err := ParseOutput(bytes.NewBufferString("\xef\xbb\xbf" + combinedExample))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 3 {
+		t.Fatalf("Unexpected set length: wanted 3, found %d: %v", len(set), set)
+	}
+	assert(t, "LISTEN", set[0].State)
+	assert(t, "SYN_RECEIVED", set[1].State)
+	assert(t, time.Date(2019, 10, 16, 10, 10, 56, 0, time.UTC).Unix(), set[1].CreationTime.Unix())
+
+	u := set[2]
+	assert(t, "udp", u.Proto)
+	assert(t, "0.0.0.0:5353", u.SrcAddr.String())
+	assert(t, "", u.DstAddr.String())
+	assert(t, "", u.State)
+	assert(t, time.Date(2019, 10, 16, 8, 10, 56, 789000000, time.UTC).Unix(), u.CreationTime.Unix())
+}
+
+func TestParseOutput_Invalid(t *testing.T) {
+	t.Parallel()
+
+	set, err := ParseOutput(bytes.NewBufferString("  \r\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, 0, len(set))
+
+	for _, v := range []string{
+		`[{"LocalAddress": "x", "LocalPort": 1}]`,
+		`[{"LocalAddress": "::1", "CreationTime": "yesterday"}]`,
+		`{"tcp": 1}`,
+		`not json`,
+	} {
+		if _, err := ParseOutput(bytes.NewBufferString(v)); err == nil {
+			t.Fatalf("expected error parsing %s", v)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	t.Parallel()
+
+	d, err := ParseDate("/Date(-1000)/")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, int64(-1), d.Unix())
+	for _, v := range []string{"/Date(abc)/", "/Date()/", "/Date(-)/", ""} {
+		if _, err := ParseDate(v); err == nil {
+			t.Fatalf("expected error parsing date %q", v)
+		}
+	}
+}
+
+func assert(t *testing.T, exp, x interface{}) {
+	if !reflect.DeepEqual(exp, x) {
+		t.Fatalf("Assert failed: expected %v, found %v", exp, x)
+	}
+}
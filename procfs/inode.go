@@ -0,0 +1,131 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package procfs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Owner identifies a process file descriptor pointing to a socket.
+type Owner struct {
+	Pid int
+	Cmd string // content of /proc/<pid>/comm
+	Fd  int
+}
+
+// InodeIndex maps socket inodes to the file descriptors that refer to them.
+// The same socket may be shared by more than one process, e.g. after a fork.
+type InodeIndex map[uint64][]Owner
+
+// Lookup returns the owners of the socket identified by "inode", sorted
+// by pid and fd. The result is empty if the socket could not be attributed.
+func (idx InodeIndex) Lookup(inode uint64) []Owner {
+	if inode == 0 {
+		// Sockets in TIME_WAIT, for example, are no longer
+		// associated with an inode.
+		return nil
+	}
+	return idx[inode]
+}
+
+// ScanInodes walks every "Root/<pid>/fd" directory and builds an index
+// of the socket inodes found. Processes that disappear while scanning, or
+// that we are not allowed to inspect, are skipped: their sockets will not
+// be present in the index.
+func ScanInodes() (InodeIndex, error) {
+	entries, err := ioutil.ReadDir(Root)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list processes: %w", err)
+	}
+	idx := InodeIndex{}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		if err := idx.scanProcess(pid); err != nil {
+			log.Printf("skipping process %d: %v", pid, err)
+		}
+	}
+	for _, owners := range idx {
+		sort.Slice(owners, func(i, j int) bool {
+			if owners[i].Pid != owners[j].Pid {
+				return owners[i].Pid < owners[j].Pid
+			}
+			return owners[i].Fd < owners[j].Fd
+		})
+	}
+	return idx, nil
+}
+
+func (idx InodeIndex) scanProcess(pid int) error {
+	dir := filepath.Join(Root, strconv.Itoa(pid), "fd")
+	fds, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var cmd string
+	for _, v := range fds {
+		fd, err := strconv.Atoi(v.Name())
+		if err != nil {
+			continue
+		}
+		link, err := os.Readlink(filepath.Join(dir, v.Name()))
+		if err != nil {
+			// The file descriptor was closed in the meantime.
+			continue
+		}
+		inode, err := ParseSocketLink(link)
+		if err != nil {
+			continue
+		}
+		if cmd == "" {
+			cmd = readComm(pid)
+		}
+		idx[inode] = append(idx[inode], Owner{Pid: pid, Cmd: cmd, Fd: fd})
+	}
+	return nil
+}
+
+func readComm(pid int) string {
+	b, err := ioutil.ReadFile(filepath.Join(Root, strconv.Itoa(pid), "comm"))
+	if err != nil {
+		log.Printf("unable to read command of process %d: %v", pid, err)
+		return ""
+	}
+	return strings.TrimRight(string(b), "\n")
+}
+
+// ParseSocketLink extracts the inode from the target of a file descriptor
+// symbolic link pointing to a socket, which is in the form "socket:[12345]".
+func ParseSocketLink(link string) (uint64, error) {
+	if !strings.HasPrefix(link, "socket:[") || !strings.HasSuffix(link, "]") {
+		return 0, fmt.Errorf("%s is not a socket", link)
+	}
+	raw := strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")
+	inode, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing inode: %w", err)
+	}
+	return inode, nil
+}
@@ -0,0 +1,89 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package procfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSocketLink(t *testing.T) {
+	t.Parallel()
+
+	inode, err := ParseSocketLink("socket:[21445]")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, uint64(21445), inode)
+
+	for _, v := range []string{"pipe:[21445]", "/dev/null", "socket:[abc]", "socket:[12"} {
+		if _, err := ParseSocketLink(v); err == nil {
+			t.Fatalf("expected error parsing \"%s\"", v)
+		}
+	}
+}
+
+func TestScanInodes(t *testing.T) {
+	root, err := ioutil.TempDir("", "procfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	mkproc(t, root, "12", "nginx", map[string]string{
+		"0": "/dev/null",
+		"6": "socket:[1001]",
+		"7": "socket:[1002]",
+	})
+	mkproc(t, root, "13", "nginx", map[string]string{
+		"6": "socket:[1001]",
+	})
+	mkproc(t, root, "self", "", nil)
+	if err := os.MkdirAll(filepath.Join(root, "14"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	old := Root
+	Root = root
+	defer func() { Root = old }()
+
+	idx, err := ScanInodes()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, 2, len(idx))
+	assert(t, []Owner{{12, "nginx", 6}, {13, "nginx", 6}}, idx.Lookup(1001))
+	assert(t, []Owner{{12, "nginx", 7}}, idx.Lookup(1002))
+	assert(t, 0, len(idx.Lookup(1003)))
+	assert(t, 0, len(idx.Lookup(0)))
+}
+
+func mkproc(t *testing.T, root, pid, comm string, fds map[string]string) {
+	dir := filepath.Join(root, pid)
+	if err := os.MkdirAll(filepath.Join(dir, "fd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "comm"), []byte(comm+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for fd, target := range fds {
+		if err := os.Symlink(target, filepath.Join(dir, "fd", fd)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package procfs reads the socket tables the Linux kernel exposes under
+// /proc/net, without relying on any external tool.
+package procfs
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jecoz/lsaddr/internal"
+)
+
+// Root is the mount point of the proc filesystem.
+var Root = "/proc"
+
+// Tables lists the files under "Root/net" that are read by Run, together
+// with the network they describe.
+var Tables = []struct {
+	Name    string
+	Network string
+}{
+	{"tcp", "tcp"},
+	{"tcp6", "tcp"},
+	{"udp", "udp"},
+	{"udp6", "udp"},
+}
+
+// State is the connection state as reported by the kernel, see
+// include/net/tcp_states.h.
+type State uint8
+
+// Kernel socket states.
+const (
+	Established State = iota + 1
+	SynSent
+	SynRecv
+	FinWait1
+	FinWait2
+	TimeWait
+	Close
+	CloseWait
+	LastAck
+	Listen
+	Closing
+	NewSynRecv
+)
+
+var stateNames = map[State]string{
+	Established: "ESTABLISHED",
+	SynSent:     "SYN_SENT",
+	SynRecv:     "SYN_RECV",
+	FinWait1:    "FIN_WAIT1",
+	FinWait2:    "FIN_WAIT2",
+	TimeWait:    "TIME_WAIT",
+	Close:       "CLOSE",
+	CloseWait:   "CLOSE_WAIT",
+	LastAck:     "LAST_ACK",
+	Listen:      "LISTEN",
+	Closing:     "CLOSING",
+	NewSynRecv:  "NEW_SYN_RECV",
+}
+
+func (s State) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN(%02X)", uint8(s))
+}
+
+// Socket is a single entry of a /proc/net socket table.
+type Socket struct {
+	Raw     string
+	Network string // tcp, udp
+	IPv6    bool
+	SrcAddr net.Addr
+	DstAddr net.Addr
+	State   State
+	TxQueue uint64
+	RxQueue uint64
+	Uid     int
+	Inode   uint64
+}
+
+// Run reads every table listed in Tables and returns the sockets found.
+// Tables that do not exist, e.g. because IPv6 is disabled, are skipped.
+func Run() ([]Socket, error) {
+	acc := []Socket{}
+	for _, t := range Tables {
+		path := filepath.Join(Root, "net", t.Name)
+		log.Printf("Reading: %s", path)
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			log.Printf("skipping socket table %s: %v", path, err)
+			continue
+		}
+		if err != nil {
+			return acc, fmt.Errorf("unable to read socket table: %w", err)
+		}
+		set, err := ParseOutput(f, t.Network)
+		f.Close()
+		if err != nil {
+			return acc, fmt.Errorf("unable to parse %s: %w", path, err)
+		}
+		acc = append(acc, set...)
+	}
+	return acc, nil
+}
+
+// ParseOutput expects "r" to contain the content of a /proc/net/{tcp,udp}[6]
+// table, "network" being the protocol the table refers to. The header
+// line and each line that ``ParseSocket'' is not able to parse are skipped.
+// Returns an error only if reading from "r" produces an error
+// different from ``io.EOF''.
+func ParseOutput(r io.Reader, network string) ([]Socket, error) {
+	set := []Socket{}
+	err := internal.ScanLines(r, func(line string) error {
+		s, err := ParseSocket(line, network)
+		if err != nil {
+			log.Printf("skipping socket \"%s\": %v", line, err)
+			return nil
+		}
+		set = append(set, *s)
+		return nil
+	})
+	return set, err
+}
+
+// ParseDump is like ParseOutput, but accepts the concatenation of more
+// tables, e.g. the output of ``cat /proc/net/tcp /proc/net/udp6''. The
+// network of each table is detected from its header line, as the header
+// of udp tables has some additional columns. Lines preceding any header
+// are considered tcp sockets.
+func ParseDump(r io.Reader) ([]Socket, error) {
+	set := []Socket{}
+	network := "tcp"
+	err := internal.ScanLines(r, func(line string) error {
+		if strings.Contains(line, "local_address") {
+			network = "tcp"
+			if strings.Contains(line, "drops") {
+				network = "udp"
+			}
+			return nil
+		}
+		s, err := ParseSocket(line, network)
+		if err != nil {
+			log.Printf("skipping socket \"%s\": %v", line, err)
+			return nil
+		}
+		set = append(set, *s)
+		return nil
+	})
+	return set, err
+}
+
+// ParseSocket expects "line" to be a single entry of a /proc/net socket
+// table. The line is unmarshaled into a ``Socket'' only if it is splittable
+// by " " into a slice of at least 10 items.
+//
+// "line" examples:
+// "   0: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 21445 1 0000000000000000 100 0 0 10 0"
+// "  12: 00000000000000000000000001000000:0035 00000000000000000000000000000000:0000 07 00000000:00000000 00:00000000 00000000   101        0 17364 2 0000000000000000 0"
+func ParseSocket(line, network string) (*Socket, error) {
+	chunks, err := internal.ChunkLine(line, " ", 10)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(chunks[0], ":") {
+		return nil, fmt.Errorf("unexpected slot \"%s\"", chunks[0])
+	}
+
+	src, err := ParseAddr(network, chunks[1])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing local address: %w", err)
+	}
+	dst, err := ParseAddr(network, chunks[2])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing remote address: %w", err)
+	}
+	st, err := strconv.ParseUint(chunks[3], 16, 8)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing state: %w", err)
+	}
+	queues := strings.Split(chunks[4], ":")
+	if len(queues) != 2 {
+		return nil, fmt.Errorf("unexpected queue field \"%s\"", chunks[4])
+	}
+	tx, err := strconv.ParseUint(queues[0], 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing tx queue: %w", err)
+	}
+	rx, err := strconv.ParseUint(queues[1], 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing rx queue: %w", err)
+	}
+	uid, err := strconv.Atoi(chunks[7])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing uid: %w", err)
+	}
+	inode, err := strconv.ParseUint(chunks[9], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing inode: %w", err)
+	}
+
+	return &Socket{
+		Raw:     line,
+		Network: network,
+		IPv6:    strings.Index(chunks[1], ":") == 32,
+		SrcAddr: src,
+		DstAddr: dst,
+		State:   State(st),
+		TxQueue: tx,
+		RxQueue: rx,
+		Uid:     uid,
+		Inode:   inode,
+	}, nil
+}
+
+// ParseAddr decodes an address in the form used by the kernel socket
+// tables, i.e. "0100007F:0050": the ip is an hex dump of 32 bit words in
+// host byte order, while the port is an hex number. Unspecified remote
+// addresses, such as the ones of listening sockets, are returned as empty
+// addresses, matching what lsof does.
+func ParseAddr(network, s string) (net.Addr, error) {
+	chunks := strings.Split(s, ":")
+	if len(chunks) != 2 {
+		return nil, fmt.Errorf("unexpected address \"%s\"", s)
+	}
+	ip, err := ParseIP(chunks[0])
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(chunks[1], 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing port: %w", err)
+	}
+	if ip.IsUnspecified() && port == 0 {
+		return internal.NewAddr(network, ""), nil
+	}
+	addr := net.JoinHostPort(ip.String(), strconv.FormatUint(port, 10))
+	return internal.NewAddr(network, addr), nil
+}
+
+// ParseIP decodes the hex representation of an IPv4 or IPv6 address
+// used in /proc/net tables.
+func ParseIP(s string) (net.IP, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding ip: %w", err)
+	}
+	if len(b) != net.IPv4len && len(b) != net.IPv6len {
+		return nil, fmt.Errorf("unexpected ip length %d", len(b))
+	}
+	ip := make(net.IP, len(b))
+	for i := 0; i < len(b); i += 4 {
+		internal.NativeEndian.PutUint32(ip[i:], binary.BigEndian.Uint32(b[i:]))
+	}
+	return ip, nil
+}
@@ -0,0 +1,129 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package procfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/jecoz/lsaddr/internal"
+)
+
+const tcpExample = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 21445 1 0000000000000000 100 0 0 10 0
+   1: 3D00A8C0:CA5D 2F0EBA23:01BB 01 00000000:00000000 02:00000A9B 00000000  1000        0 184033 2 0000000000000000 20 4 30 10 -1
+`
+
+func TestParseOutput(t *testing.T) {
+	t.Parallel()
+	if internal.NativeEndian != binary.LittleEndian {
+		t.Skip("fixtures are encoded in little endian byte order")
+	}
+
+	buf := bytes.NewBufferString(tcpExample)
+	set, err := ParseOutput(buf, "tcp")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 2 {
+		t.Fatalf("Unexpected set length: wanted 2, found %d: %v", len(set), set)
+	}
+	assert(t, "127.0.0.1:3306", set[0].SrcAddr.String())
+	assert(t, "", set[0].DstAddr.String())
+	assert(t, Listen, set[0].State)
+	assert(t, 999, set[0].Uid)
+	assert(t, uint64(21445), set[0].Inode)
+
+	assert(t, "192.168.0.61:51805", set[1].SrcAddr.String())
+	assert(t, "35.186.14.47:443", set[1].DstAddr.String())
+	assert(t, "tcp", set[1].DstAddr.Network())
+	assert(t, Established, set[1].State)
+}
+
+const dumpExample = tcpExample + `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  12: 00000000000000000000000001000000:0035 00000000000000000000000000000000:0000 07 00000000:00000000 00:00000000 00000000   101        0 17364 2 0000000000000000 0
+`
+
+func TestParseDump(t *testing.T) {
+	t.Parallel()
+	if internal.NativeEndian != binary.LittleEndian {
+		t.Skip("fixtures are encoded in little endian byte order")
+	}
+
+	set, err := ParseDump(bytes.NewBufferString(dumpExample))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 3 {
+		t.Fatalf("Unexpected set length: wanted 3, found %d: %v", len(set), set)
+	}
+	assert(t, "tcp", set[1].Network)
+	assert(t, "udp", set[2].Network)
+	assert(t, false, set[1].IPv6)
+	assert(t, true, set[2].IPv6)
+	assert(t, "[::1]:53", set[2].SrcAddr.String())
+	assert(t, Close, set[2].State)
+}
+
+func TestParseAddr(t *testing.T) {
+	t.Parallel()
+	if internal.NativeEndian != binary.LittleEndian {
+		t.Skip("fixtures are encoded in little endian byte order")
+	}
+
+	tt := []struct {
+		in  string
+		out string
+	}{
+		{"0100007F:0050", "127.0.0.1:80"},
+		{"00000000:0000", ""},
+		{"00000000:0035", "0.0.0.0:53"},
+		{"00000000000000000000000001000000:0035", "[::1]:53"},
+		{"0000000000000000FFFF00000100007F:1F90", "127.0.0.1:8080"},
+		{"B80D0120000000000000000001000000:01BB", "[2001:db8::1]:443"},
+	}
+	for i, v := range tt {
+		addr, err := ParseAddr("udp", v.in)
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		assert(t, v.out, addr.String())
+		assert(t, "udp", addr.Network())
+	}
+}
+
+func TestParseSocket_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tt := []string{
+		"  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode",
+		"   0: 0100007F:0CEA 00000000 0A 00000000:00000000 00:00000000 00000000   999        0 21445",
+		"   0: 0100007G:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 21445",
+	}
+	for i, v := range tt {
+		if _, err := ParseSocket(v, "tcp"); err == nil {
+			t.Fatalf("%d: expected error parsing \"%s\"", i, v)
+		}
+	}
+}
+
+func assert(t *testing.T, exp, x interface{}) {
+	if !reflect.DeepEqual(exp, x) {
+		t.Fatalf("Assert failed: expected %v, found %v", exp, x)
+	}
+}
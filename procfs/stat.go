@@ -0,0 +1,100 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package procfs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Process is the subset of /proc/<pid>/stat needed to build the
+// process tree.
+type Process struct {
+	Pid   int
+	PPid  int    // 0 for processes started by the kernel
+	Comm  string // executable name, truncated by the kernel to 15 characters
+	State string // R, S, D, Z, ...
+}
+
+// ParseStat parses the content of /proc/<pid>/stat. The command name is
+// enclosed in parentheses and may contain spaces and parentheses itself,
+// hence the fields are located starting from the last ")".
+//
+// "line" example:
+// "1234 (Web Content) S 1200 1200 1200 0 -1 4194560 ..."
+func ParseStat(line string) (*Process, error) {
+	open := strings.Index(line, "(")
+	close := strings.LastIndex(line, ")")
+	if open < 0 || close < open {
+		return nil, fmt.Errorf("unable to find command name in \"%s\"", line)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(line[:open]))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing pid: %w", err)
+	}
+	fields := strings.Fields(line[close+1:])
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("expected at least 2 fields after command name, found %d", len(fields))
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing ppid: %w", err)
+	}
+	return &Process{
+		Pid:   pid,
+		PPid:  ppid,
+		Comm:  line[open+1 : close],
+		State: fields[0],
+	}, nil
+}
+
+// ReadStat reads and parses "Root/<pid>/stat".
+func ReadStat(pid int) (*Process, error) {
+	b, err := ioutil.ReadFile(filepath.Join(Root, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return nil, err
+	}
+	return ParseStat(strings.TrimSpace(string(b)))
+}
+
+// ScanProcesses reads the stat file of every process found under "Root",
+// sorted by pid. Processes that disappear while scanning are skipped.
+func ScanProcesses() ([]Process, error) {
+	entries, err := ioutil.ReadDir(Root)
+	if err != nil {
+		return []Process{}, fmt.Errorf("unable to list processes: %w", err)
+	}
+	set := []Process{}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		p, err := ReadStat(pid)
+		if err != nil {
+			log.Printf("skipping process %d: %v", pid, err)
+			continue
+		}
+		set = append(set, *p)
+	}
+	sort.Slice(set, func(i, j int) bool { return set[i].Pid < set[j].Pid })
+	return set, nil
+}
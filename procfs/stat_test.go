@@ -0,0 +1,76 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package procfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseStat(t *testing.T) {
+	t.Parallel()
+
+	p, err := ParseStat("1234 (Web Content) S 1200 1200 1200 0 -1 4194560 4212 0 0 0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, Process{Pid: 1234, PPid: 1200, Comm: "Web Content", State: "S"}, *p)
+
+	if p, err = ParseStat("42 (a) b)) R 1 42 42"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, "a) b)", p.Comm)
+	assert(t, 1, p.PPid)
+
+	for _, v := range []string{"", "12 sh S 1", "x (sh) S 1", "12 (sh) S", "12 (sh) S x"} {
+		if _, err := ParseStat(v); err == nil {
+			t.Fatalf("expected error parsing \"%s\"", v)
+		}
+	}
+}
+
+func TestScanProcesses(t *testing.T) {
+	root, err := ioutil.TempDir("", "procfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for pid, stat := range map[string]string{
+		"1":   "1 (systemd) S 0 1 1 0 -1",
+		"120": "120 (code) S 1 120 120 0 -1",
+		"121": "121 (code helper) S 120 120 120 0 -1",
+	} {
+		mkproc(t, root, pid, "", nil)
+		if err := ioutil.WriteFile(filepath.Join(root, pid, "stat"), []byte(stat+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mkproc(t, root, "122", "", nil) // exited while scanning
+
+	old := Root
+	Root = root
+	defer func() { Root = old }()
+
+	set, err := ScanProcesses()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, 3, len(set))
+	assert(t, Process{Pid: 121, PPid: 120, Comm: "code helper", State: "S"}, set[2])
+}
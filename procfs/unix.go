@@ -0,0 +1,130 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package procfs
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jecoz/lsaddr/internal"
+)
+
+// Unix socket types, see include/linux/net.h.
+var unixTypes = map[uint64]string{
+	1: "stream",
+	2: "dgram",
+	3: "raw",
+	4: "rdm",
+	5: "seqpacket",
+}
+
+// unixAcceptCon is the flag set on listening unix sockets.
+const unixAcceptCon = 1 << 16
+
+// UnixSocket is a single entry of /proc/net/unix.
+type UnixSocket struct {
+	Raw       string
+	Path      string // empty for unnamed sockets, starts with "@" for abstract ones
+	Type      string // stream, dgram, seqpacket
+	Listening bool
+	Connected bool
+	Inode     uint64
+	Peer      uint64 // inode of the peer socket, 0 if unknown
+}
+
+// RunUnix reads the unix sockets table "Root/net/unix". The table does
+// not report peers.
+func RunUnix() ([]UnixSocket, error) {
+	path := filepath.Join(Root, "net", "unix")
+	log.Printf("Reading: %s", path)
+	f, err := os.Open(path)
+	if err != nil {
+		return []UnixSocket{}, fmt.Errorf("unable to read socket table: %w", err)
+	}
+	defer f.Close()
+	return ParseUnixOutput(f)
+}
+
+// ParseUnixOutput expects "r" to contain the content of /proc/net/unix.
+// The header line and each line that `ParseUnixSocket` is not able to
+// parse are skipped. Returns an error only if reading from "r" produces an
+// error different from `io.EOF`.
+func ParseUnixOutput(r io.Reader) ([]UnixSocket, error) {
+	set := []UnixSocket{}
+	err := internal.ScanLines(r, func(line string) error {
+		s, err := ParseUnixSocket(line)
+		if err != nil {
+			log.Printf("skipping unix socket \"%s\": %v", line, err)
+			return nil
+		}
+		set = append(set, *s)
+		return nil
+	})
+	return set, err
+}
+
+// ParseUnixSocket expects "line" to be a single entry of /proc/net/unix.
+// The line is unmarshaled into a `UnixSocket` only if it is splittable
+// by " " into a slice of at least 7 items. The path, if present, is the
+// remaining part of the line and may contain spaces.
+//
+// "line" examples:
+// "0000000032a89f02: 00000002 00000000 00010000 0001 01  3765 /run/docker.sock"
+// "00000000a5fb9944: 00000003 00000000 00000000 0001 03   904"
+func ParseUnixSocket(line string) (*UnixSocket, error) {
+	chunks, err := internal.ChunkLine(line, " ", 7)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(chunks[0], ":") {
+		return nil, fmt.Errorf("unexpected slot \"%s\"", chunks[0])
+	}
+	flags, err := strconv.ParseUint(chunks[3], 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing flags: %w", err)
+	}
+	typ, err := strconv.ParseUint(chunks[4], 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing type: %w", err)
+	}
+	st, err := strconv.ParseUint(chunks[5], 16, 8)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing state: %w", err)
+	}
+	inode, err := strconv.ParseUint(chunks[6], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing inode: %w", err)
+	}
+
+	s := &UnixSocket{
+		Raw:       line,
+		Type:      unixTypes[typ],
+		Listening: flags&unixAcceptCon != 0,
+		Connected: st == 3, // SS_CONNECTED
+		Inode:     inode,
+	}
+	if len(chunks) > 7 {
+		// Find where the path starts, as it may contain spaces.
+		idx := strings.Index(line, " "+chunks[6]+" ")
+		s.Path = line[idx+len(chunks[6])+2:]
+	}
+	return s, nil
+}
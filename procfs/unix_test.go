@@ -0,0 +1,56 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package procfs
+
+import (
+	"bytes"
+	"testing"
+)
+
+const unixExample = `Num       RefCount Protocol Flags    Type St Inode Path
+0000000032a89f02: 00000002 00000000 00010000 0001 01  3765 /run/docker.sock
+00000000a5fb9944: 00000003 00000000 00000000 0001 03   904
+0000000093a4b1c8: 00000002 00000000 00000000 0002 01 21391 @/tmp/.X11-unix/X0
+00000000fdf1b1be: 00000003 00000000 00000000 0005 03 20166 /tmp/my app/app.sock
+`
+
+func TestParseUnixOutput(t *testing.T) {
+	t.Parallel()
+
+	set, err := ParseUnixOutput(bytes.NewBufferString(unixExample))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 4 {
+		t.Fatalf("Unexpected set length: wanted 4, found %d: %v", len(set), set)
+	}
+
+	assert(t, "/run/docker.sock", set[0].Path)
+	assert(t, "stream", set[0].Type)
+	assert(t, true, set[0].Listening)
+	assert(t, false, set[0].Connected)
+	assert(t, uint64(3765), set[0].Inode)
+
+	assert(t, "", set[1].Path)
+	assert(t, true, set[1].Connected)
+	assert(t, false, set[1].Listening)
+
+	assert(t, "@/tmp/.X11-unix/X0", set[2].Path)
+	assert(t, "dgram", set[2].Type)
+
+	assert(t, "/tmp/my app/app.sock", set[3].Path)
+	assert(t, "seqpacket", set[3].Type)
+}
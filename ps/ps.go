@@ -0,0 +1,102 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package ps builds the process table from the output of `ps`, on the
+// systems that do not expose it through the proc filesystem.
+package ps
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/tool"
+)
+
+// Process is a single entry of the process table.
+type Process struct {
+	Raw  string
+	Pid  int
+	PPid int
+	Comm string // command name, which may be a full path and contain spaces
+}
+
+// Run executes ``ps -axo pid=,ppid=,comm='', configured with "opts", and
+// parses its output. Execution errors are of type *tool.Error.
+func Run(ctx context.Context, opts tool.Options) ([]Process, error) {
+	out, err := tool.Run(ctx, opts, "ps", "-axo", "pid=,ppid=,comm=")
+	if err != nil {
+		return []Process{}, fmt.Errorf("unable to run ps: %w", err)
+	}
+	return ParseOutput(bytes.NewBuffer(out))
+}
+
+// ParseOutput expects "r" to contain the output of a
+// ``ps -axo pid=,ppid=,comm='' call. Each line that ``ParseProcess'' is
+// able to parse is appended to the final output.
+// Returns an error only if reading from "r" produces an error
+// different from ``io.EOF''.
+func ParseOutput(r io.Reader) ([]Process, error) {
+	set := []Process{}
+	err := internal.ScanLines(r, func(line string) error {
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		p, err := ParseProcess(line)
+		if err != nil {
+			log.Printf("skipping process \"%s\": %v", line, err)
+			return nil
+		}
+		set = append(set, *p)
+		return nil
+	})
+	return set, err
+}
+
+// ParseProcess parses a single line of ``ps -axo pid=,ppid=,comm=''.
+// The command name is the remainder of the line, spaces included.
+//
+// "line" example:
+// "  912     1 /Applications/Visual Studio Code.app/Contents/MacOS/Electron"
+func ParseProcess(line string) (*Process, error) {
+	rest := strings.TrimSpace(line)
+	fields := make([]int, 2)
+	for i := range fields {
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			end = len(rest)
+		}
+		n, err := strconv.Atoi(rest[:end])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing field %d: %w", i+1, err)
+		}
+		fields[i] = n
+		rest = strings.TrimLeft(rest[end:], " \t")
+	}
+	if rest == "" {
+		return nil, fmt.Errorf("missing command name")
+	}
+	return &Process{
+		Raw:  line,
+		Pid:  fields[0],
+		PPid: fields[1],
+		Comm: rest,
+	}, nil
+}
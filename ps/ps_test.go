@@ -0,0 +1,60 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package ps
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+const psExample = `    1     0 /sbin/launchd
+  912     1 /Applications/Visual Studio Code.app/Contents/MacOS/Electron
+  930   912 /Applications/Visual Studio Code.app/Contents/Frameworks/Code Helper (Renderer).app/Contents/MacOS/Code Helper (Renderer)
+ garbage
+`
+
+func TestParseOutput(t *testing.T) {
+	t.Parallel()
+
+	set, err := ParseOutput(bytes.NewBufferString(psExample))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 3 {
+		t.Fatalf("Unexpected set length: wanted 3, found %d: %v", len(set), set)
+	}
+	assert(t, 912, set[1].Pid)
+	assert(t, 1, set[1].PPid)
+	assert(t, "/Applications/Visual Studio Code.app/Contents/MacOS/Electron", set[1].Comm)
+	assert(t, 912, set[2].PPid)
+}
+
+func TestParseProcess_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{"", "12", "12 1", "x 1 sh", "12 y sh"} {
+		if _, err := ParseProcess(v); err == nil {
+			t.Fatalf("expected error parsing \"%s\"", v)
+		}
+	}
+}
+
+func assert(t *testing.T, exp, x interface{}) {
+	if !reflect.DeepEqual(exp, x) {
+		t.Fatalf("Assert failed: expected %v, found %v", exp, x)
+	}
+}
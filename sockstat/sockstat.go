@@ -0,0 +1,168 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package sockstat parses the output of `sockstat`, which lists the open
+// sockets on FreeBSD and the other BSDs, where lsof is often not installed.
+package sockstat
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/tool"
+)
+
+// Unknown is printed by sockstat in place of the owner of sockets that
+// are not associated with any process.
+const Unknown = "?"
+
+// Socket is a single socket reported by sockstat.
+type Socket struct {
+	Raw     string
+	User    string // empty if unknown
+	Command string // empty if unknown
+	Pid     int    // 0 if unknown
+	Fd      int    // -1 if unknown
+	Proto   string // e.g. tcp4, tcp6, tcp46, udp4
+	Network string // tcp, udp
+	IPv6    bool   // true for tcp46 and udp46 sockets too
+	SrcAddr net.Addr
+	DstAddr net.Addr
+	State   string // only present when the -s option is used
+}
+
+// Run executes ``sockstat -46 -c -l'', configured with "opts", and
+// parses its output. Execution errors are of type *tool.Error.
+func Run(ctx context.Context, opts tool.Options) ([]Socket, error) {
+	out, err := tool.Run(ctx, opts, "sockstat", "-46", "-c", "-l")
+	if err != nil {
+		return []Socket{}, fmt.Errorf("unable to run sockstat: %w", err)
+	}
+	return ParseOutput(bytes.NewBuffer(out))
+}
+
+// ParseOutput expects "r" to contain the output of a ``sockstat -46''
+// call. Each line that ``ParseSocket'' is able to parse is appended to
+// the final output.
+// Returns an error only if reading from "r" produces an error
+// different from ``io.EOF''.
+func ParseOutput(r io.Reader) ([]Socket, error) {
+	set := []Socket{}
+	err := internal.ScanLines(r, func(line string) error {
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		s, err := ParseSocket(line)
+		if err != nil {
+			log.Printf("skipping sockstat socket \"%s\": %v", line, err)
+			return nil
+		}
+		set = append(set, *s)
+		return nil
+	})
+	return set, err
+}
+
+// ParseSocket parses a single line of ``sockstat -46'', which contains the
+// USER, COMMAND, PID, FD, PROTO, LOCAL ADDRESS and FOREIGN ADDRESS
+// columns, optionally followed by the state printed with -s.
+//
+// "line" examples:
+// "www      nginx      900   6  tcp4   192.168.1.10:80       10.0.0.5:51234"
+// "?        ?          ?     ?  tcp4   10.0.0.1:22           10.0.0.2:5555"
+func ParseSocket(line string) (*Socket, error) {
+	chunks, err := internal.ChunkLine(line, " ", 7)
+	if err != nil {
+		return nil, err
+	}
+	s := &Socket{
+		Raw:   line,
+		Proto: chunks[4],
+		Fd:    -1,
+	}
+	switch {
+	case strings.HasPrefix(s.Proto, "tcp"):
+		s.Network = "tcp"
+	case strings.HasPrefix(s.Proto, "udp"):
+		s.Network = "udp"
+	default:
+		return nil, fmt.Errorf("unsupported protocol %s", s.Proto)
+	}
+	s.IPv6 = strings.HasSuffix(s.Proto, "6")
+
+	if chunks[0] != Unknown {
+		s.User = chunks[0]
+	}
+	if chunks[1] != Unknown {
+		s.Command = chunks[1]
+	}
+	if chunks[2] != Unknown {
+		if s.Pid, err = strconv.Atoi(chunks[2]); err != nil {
+			return nil, fmt.Errorf("error parsing pid: %w", err)
+		}
+	}
+	if chunks[3] != Unknown {
+		if s.Fd, err = strconv.Atoi(chunks[3]); err != nil {
+			return nil, fmt.Errorf("error parsing fd: %w", err)
+		}
+	}
+	if s.SrcAddr, err = ParseAddr(s.Network, chunks[5]); err != nil {
+		return nil, fmt.Errorf("error parsing local address: %w", err)
+	}
+	if s.DstAddr, err = ParseAddr(s.Network, chunks[6]); err != nil {
+		return nil, fmt.Errorf("error parsing foreign address: %w", err)
+	}
+	if len(chunks) > 7 {
+		s.State = chunks[7]
+	}
+	return s, nil
+}
+
+// ParseAddr parses an address as printed by sockstat, e.g. "10.0.0.1:22",
+// "*:22", "::1:514" or "*:*". IPv6 hosts are not enclosed in brackets,
+// hence the port is the string after the last colon. Unspecified peers
+// are returned as empty addresses.
+func ParseAddr(network, s string) (net.Addr, error) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return nil, fmt.Errorf("missing port in address %s", s)
+	}
+	host, port := s[:i], s[i+1:]
+	if port == "*" {
+		return internal.NewAddr(network, ""), nil
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return nil, fmt.Errorf("invalid port in address %s", s)
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "*" {
+		return internal.NewAddr(network, "*:"+port), nil
+	}
+	ip := host
+	if j := strings.Index(host, "%"); j >= 0 {
+		ip = host[:j]
+	}
+	if net.ParseIP(ip) == nil {
+		return nil, fmt.Errorf("invalid host in address %s", s)
+	}
+	return internal.NewAddr(network, net.JoinHostPort(host, port)), nil
+}
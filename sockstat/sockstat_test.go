@@ -0,0 +1,108 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package sockstat
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+const outputExample = `USER     COMMAND    PID   FD PROTO  LOCAL ADDRESS         FOREIGN ADDRESS
+root     sshd       812   4  tcp6   *:22                  *:*
+root     sshd       812   5  tcp4   *:22                  *:*
+www      nginx      900   6  tcp4   192.168.1.10:80       10.0.0.5:51234
+root     syslogd    600   7  udp6   ::1:514               *:*
+root     ntpd       700   21 udp46  fe80::1%lo0:123       *:*
+?        ?          ?     ?  tcp4   10.0.0.1:22           10.0.0.2:5555
+root     sctpd      701   3  sctp4  10.0.0.1:9899         *:*
+`
+
+func TestParseOutput(t *testing.T) {
+	t.Parallel()
+
+	set, err := ParseOutput(bytes.NewBufferString(outputExample))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 6 {
+		t.Fatalf("Unexpected set length: wanted 6, found %d: %v", len(set), set)
+	}
+
+	s := set[0]
+	assert(t, "root", s.User)
+	assert(t, "sshd", s.Command)
+	assert(t, 812, s.Pid)
+	assert(t, 4, s.Fd)
+	assert(t, "tcp6", s.Proto)
+	assert(t, "tcp", s.Network)
+	assert(t, true, s.IPv6)
+	assert(t, "*:22", s.SrcAddr.String())
+	assert(t, "", s.DstAddr.String())
+
+	s = set[2]
+	assert(t, false, s.IPv6)
+	assert(t, "192.168.1.10:80", s.SrcAddr.String())
+	assert(t, "10.0.0.5:51234", s.DstAddr.String())
+	assert(t, "tcp", s.DstAddr.Network())
+
+	s = set[3]
+	assert(t, "udp", s.Network)
+	assert(t, "[::1]:514", s.SrcAddr.String())
+
+	s = set[4]
+	assert(t, true, s.IPv6)
+	assert(t, "[fe80::1%lo0]:123", s.SrcAddr.String())
+
+	s = set[5]
+	assert(t, "", s.User)
+	assert(t, "", s.Command)
+	assert(t, 0, s.Pid)
+	assert(t, -1, s.Fd)
+}
+
+func TestParseSocket_State(t *testing.T) {
+	t.Parallel()
+
+	s, err := ParseSocket("www      nginx      900   6  tcp4   192.168.1.10:80       10.0.0.5:51234     ESTABLISHED")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, "ESTABLISHED", s.State)
+}
+
+func TestParseSocket_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tt := []string{
+		"USER     COMMAND    PID   FD PROTO  LOCAL ADDRESS         FOREIGN ADDRESS",
+		"root     sshd       812   4  tcp6   *:22",
+		"root     sshd       x     4  tcp6   *:22                  *:*",
+		"root     sshd       812   4  tcp6   *:ssh                 *:*",
+		"root     sshd       812   4  tcp6   host:22               *:*",
+	}
+	for i, v := range tt {
+		if _, err := ParseSocket(v); err == nil {
+			t.Fatalf("%d: expected error parsing \"%s\"", i, v)
+		}
+	}
+}
+
+func assert(t *testing.T, exp, x interface{}) {
+	if !reflect.DeepEqual(exp, x) {
+		t.Fatalf("Assert failed: expected %v, found %v", exp, x)
+	}
+}
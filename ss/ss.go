@@ -0,0 +1,373 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package ss parses the output of `ss`, the socket statistics tool
+// shipped with iproute2, which is often available where lsof is not.
+package ss
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/tool"
+)
+
+// Owner is a process holding a file descriptor of a socket.
+type Owner struct {
+	Cmd string
+	Pid int
+	Fd  int
+}
+
+// TCPInfo is a subset of the tcp_info fields printed with the -i option.
+type TCPInfo struct {
+	Rto           time.Duration
+	Rtt           time.Duration
+	RttVar        time.Duration
+	Mss           int
+	Cwnd          int
+	Ssthresh      int
+	Unacked       int
+	Retrans       int // current retransmits
+	TotalRetrans  int
+	Lost          int
+	BytesSent     uint64
+	BytesAcked    uint64
+	BytesReceived uint64
+}
+
+// Socket is a single socket reported by ss.
+type Socket struct {
+	Raw     string
+	Network string // tcp, udp
+	State   string // e.g. ESTAB, LISTEN, UNCONN, TIME-WAIT
+	RecvQ   int
+	SendQ   int
+	SrcAddr net.Addr
+	DstAddr net.Addr
+	Owners  []Owner // more than one when the socket is shared, e.g. after a fork
+	Uid     int     // -1 if unknown
+	Inode   uint64
+	Info    *TCPInfo          // tcp only, nil if not reported
+	Extra   map[string]string // every key:value pair printed after the addresses
+}
+
+// Run executes ``ss -tuanpieO'', configured with "opts", and parses its
+// output. Execution errors are of type *tool.Error.
+func Run(ctx context.Context, opts tool.Options) ([]Socket, error) {
+	out, err := tool.Run(ctx, opts, "ss", "-tuanpieO")
+	if err != nil {
+		return []Socket{}, fmt.Errorf("unable to run ss: %w", err)
+	}
+	return ParseOutput(bytes.NewBuffer(out))
+}
+
+// ParseOutput expects "r" to contain the output of an ``ss -tuanpie''
+// call. Each line that ``ParseSocket'' is able to parse is appended to
+// the final output. Without the -O option, ss prints the extended
+// information on indented continuation lines, which are associated with
+// the preceding socket.
+// Returns an error only if reading from "r" produces an error
+// different from ``io.EOF''.
+func ParseOutput(r io.Reader) ([]Socket, error) {
+	set := []Socket{}
+	err := internal.ScanLines(r, func(line string) error {
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		if len(set) > 0 && (line[0] == ' ' || line[0] == '\t') {
+			last := &set[len(set)-1]
+			last.Raw += "\n" + line
+			return last.parseExtra(line)
+		}
+		s, err := ParseSocket(line)
+		if err != nil {
+			log.Printf("skipping ss socket \"%s\": %v", line, err)
+			return nil
+		}
+		set = append(set, *s)
+		return nil
+	})
+	return set, err
+}
+
+// ParseSocket parses a single line of ``ss -tuanpieO''.
+//
+// "line" example:
+// `tcp   ESTAB  0      0          127.0.0.1:59904    127.0.0.1:48271 users:(("curl",pid=1549,fd=18)) uid:1000 ino:24000 sk:11 <-> ts sack cubic rto:204 rtt:0.529/0.663 mss:65483 cwnd:16 bytes_acked:19145002`
+func ParseSocket(line string) (*Socket, error) {
+	chunks, err := internal.ChunkLine(line, " ", 6)
+	if err != nil {
+		return nil, err
+	}
+	network := chunks[0]
+	if network != "tcp" && network != "udp" {
+		return nil, fmt.Errorf("unsupported network %s", network)
+	}
+	recvq, err := strconv.Atoi(chunks[2])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Recv-Q: %w", err)
+	}
+	sendq, err := strconv.Atoi(chunks[3])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Send-Q: %w", err)
+	}
+	src, err := ParseAddr(network, chunks[4])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing local address: %w", err)
+	}
+	dst, err := ParseAddr(network, chunks[5])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing peer address: %w", err)
+	}
+	s := &Socket{
+		Raw:     line,
+		Network: network,
+		State:   chunks[1],
+		RecvQ:   recvq,
+		SendQ:   sendq,
+		SrcAddr: src,
+		DstAddr: dst,
+		Uid:     -1,
+	}
+	// The remaining fields are located after the peer address, which may
+	// be followed by a tab in the original line.
+	rest := line
+	for _, v := range chunks[:6] {
+		rest = rest[strings.Index(rest, v)+len(v):]
+	}
+	if err := s.parseExtra(rest); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ParseAddr parses an address as printed by ss, e.g. "127.0.0.1:80",
+// "[fe80::1]%eth0:22", "[fe80::1%eth0]:22", "127.0.0.53%lo:53" or "*:*",
+// the wildcard address of IPv6 sockets accepting IPv4 connections too.
+// IPv6 zones are kept, while the interface sockets are bound to is
+// dropped from IPv4 addresses. Unspecified peers are returned as empty
+// addresses.
+func ParseAddr(network, s string) (net.Addr, error) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return nil, fmt.Errorf("missing port in address %s", s)
+	}
+	host, port := s[:i], s[i+1:]
+	if port == "*" {
+		return internal.NewAddr(network, ""), nil
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return nil, fmt.Errorf("invalid port in address %s", s)
+	}
+	host = strings.Replace(host, "]%", "%", 1)
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "*" {
+		return internal.NewAddr(network, "*:"+port), nil
+	}
+	ip, zone := host, ""
+	if j := strings.Index(host, "%"); j >= 0 {
+		ip, zone = host[:j], host[j+1:]
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, fmt.Errorf("invalid host in address %s", s)
+	}
+	if zone != "" && parsed.To4() == nil {
+		ip += "%" + zone
+	}
+	return internal.NewAddr(network, net.JoinHostPort(ip, port)), nil
+}
+
+// splitFields splits "s" by spaces, which are ignored inside quotes and
+// parentheses, as process names may contain them.
+func splitFields(s string) []string {
+	fields := []string{}
+	depth, quoted, start := 0, false, -1
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case unicode.IsSpace(r) && depth <= 0:
+			if start >= 0 {
+				fields = append(fields, s[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		fields = append(fields, s[start:])
+	}
+	return fields
+}
+
+// valuedFlags are printed by ss as "name value" instead of "name:value".
+var valuedFlags = map[string]bool{
+	"send":          true,
+	"pacing_rate":   true,
+	"delivery_rate": true,
+}
+
+func (s *Socket) parseExtra(rest string) error {
+	fields := splitFields(rest)
+	if s.Extra == nil {
+		s.Extra = map[string]string{}
+	}
+	for i := 0; i < len(fields); i++ {
+		key, val := fields[i], ""
+		if j := strings.Index(key, ":"); j >= 0 {
+			key, val = key[:j], key[j+1:]
+		} else if valuedFlags[key] && i+1 < len(fields) {
+			val = fields[i+1]
+			i++
+		}
+		s.Extra[key] = val
+
+		var err error
+		switch key {
+		case "users":
+			s.Owners, err = ParseUsers(val)
+		case "uid":
+			s.Uid, err = strconv.Atoi(val)
+		case "ino":
+			s.Inode, err = strconv.ParseUint(val, 10, 64)
+		}
+		if err != nil {
+			return fmt.Errorf("error parsing %s: %w", key, err)
+		}
+	}
+	if _, ok := s.Extra["ino"]; ok && s.Uid < 0 {
+		// The extended information omits the uid of root.
+		s.Uid = 0
+	}
+	if s.Network == "tcp" {
+		s.Info = parseTCPInfo(s.Extra)
+	}
+	return nil
+}
+
+// ParseUsers parses the process list printed with the -p option, e.g.
+// `(("nginx",pid=12,fd=6),("nginx",pid=13,fd=6))`.
+func ParseUsers(s string) ([]Owner, error) {
+	owners := []Owner{}
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("invalid process list %s", s)
+	}
+	s = s[1 : len(s)-1]
+	for len(s) > 0 {
+		if !strings.HasPrefix(s, `("`) {
+			return nil, fmt.Errorf("invalid process entry %s", s)
+		}
+		end := strings.Index(s[2:], `",`)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated process name in %s", s)
+		}
+		o := Owner{Cmd: s[2 : 2+end], Fd: -1}
+		s = s[2+end+2:]
+		close := strings.Index(s, ")")
+		if close < 0 {
+			return nil, fmt.Errorf("unterminated process entry %s", s)
+		}
+		for _, kv := range strings.Split(s[:close], ",") {
+			var err error
+			switch {
+			case strings.HasPrefix(kv, "pid="):
+				o.Pid, err = strconv.Atoi(kv[4:])
+			case strings.HasPrefix(kv, "fd="):
+				o.Fd, err = strconv.Atoi(kv[3:])
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid process entry %s: %w", kv, err)
+			}
+		}
+		owners = append(owners, o)
+		s = strings.TrimPrefix(s[close+1:], ",")
+	}
+	return owners, nil
+}
+
+// parseTCPInfo extracts the tcp_info fields from "extra", returning nil
+// if none of them is present. Malformed values are ignored.
+func parseTCPInfo(extra map[string]string) *TCPInfo {
+	info := &TCPInfo{}
+	found := false
+	num := func(key string, dst *int) {
+		if v, ok := extra[key]; ok {
+			found = true
+			*dst, _ = strconv.Atoi(v)
+		}
+	}
+	u64 := func(key string, dst *uint64) {
+		if v, ok := extra[key]; ok {
+			found = true
+			*dst, _ = strconv.ParseUint(v, 10, 64)
+		}
+	}
+	ms := func(v string) time.Duration {
+		f, _ := strconv.ParseFloat(v, 64)
+		return time.Duration(f * float64(time.Millisecond))
+	}
+	if v, ok := extra["rto"]; ok {
+		found = true
+		info.Rto = ms(v)
+	}
+	if v, ok := extra["rtt"]; ok {
+		found = true
+		rtt := strings.SplitN(v, "/", 2)
+		info.Rtt = ms(rtt[0])
+		if len(rtt) == 2 {
+			info.RttVar = ms(rtt[1])
+		}
+	}
+	if v, ok := extra["retrans"]; ok {
+		found = true
+		retrans := strings.SplitN(v, "/", 2)
+		info.Retrans, _ = strconv.Atoi(retrans[0])
+		if len(retrans) == 2 {
+			info.TotalRetrans, _ = strconv.Atoi(retrans[1])
+		}
+	}
+	num("mss", &info.Mss)
+	num("cwnd", &info.Cwnd)
+	num("ssthresh", &info.Ssthresh)
+	num("unacked", &info.Unacked)
+	num("lost", &info.Lost)
+	u64("bytes_sent", &info.BytesSent)
+	u64("bytes_acked", &info.BytesAcked)
+	u64("bytes_received", &info.BytesReceived)
+	if !found {
+		return nil
+	}
+	return info
+}
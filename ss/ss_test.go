@@ -0,0 +1,157 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package ss
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const outputExample = `Netid State  Recv-Q Send-Q            Local Address:Port    Peer Address:Port Process
+udp   UNCONN 0      0             127.0.0.53%lo:53           0.0.0.0:*     users:(("systemd-resolve",pid=420,fd=13)) uid:101 ino:17364 sk:1 cgroup:/system.slice/systemd-resolved.service <->
+tcp   LISTEN 0      511                 0.0.0.0:80           0.0.0.0:*     users:(("nginx",pid=12,fd=6),("nginx",pid=13,fd=6)) ino:21445 sk:2 cgroup:/system.slice/nginx.service <->
+tcp   ESTAB  0      0          [fe80::1%eth0]:22     [fe80::2%eth0]:51234 ino:184033 sk:3 <-> ts sack cubic wscale:7,7 rto:204 rtt:0.529/0.663 mss:1448 cwnd:10 retrans:0/2 bytes_sent:1024 bytes_acked:1025 bytes_received:2048 send 218.9Mbps pacing_rate 437.8Mbps
+tcp   LISTEN 0      4096                      *:8080               *:*     users:(("my server",pid=99,fd=3)) uid:1000 ino:3003 sk:4 <->
+unix  STREAM 0      0      /run/systemd/notify 1234 * 0
+`
+
+func TestParseOutput(t *testing.T) {
+	t.Parallel()
+
+	set, err := ParseOutput(bytes.NewBufferString(outputExample))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 4 {
+		t.Fatalf("Unexpected set length: wanted 4, found %d: %v", len(set), set)
+	}
+
+	s := set[0]
+	assert(t, "udp", s.Network)
+	assert(t, "UNCONN", s.State)
+	assert(t, "127.0.0.53:53", s.SrcAddr.String())
+	assert(t, "", s.DstAddr.String())
+	assert(t, []Owner{{Cmd: "systemd-resolve", Pid: 420, Fd: 13}}, s.Owners)
+	assert(t, 101, s.Uid)
+	assert(t, uint64(17364), s.Inode)
+	assert(t, "/system.slice/systemd-resolved.service", s.Extra["cgroup"])
+	assert(t, (*TCPInfo)(nil), s.Info)
+
+	s = set[1]
+	assert(t, 511, s.SendQ)
+	assert(t, 2, len(s.Owners))
+	assert(t, 13, s.Owners[1].Pid)
+	assert(t, 0, s.Uid)
+
+	s = set[2]
+	assert(t, "[fe80::1%eth0]:22", s.SrcAddr.String())
+	assert(t, "[fe80::2%eth0]:51234", s.DstAddr.String())
+	assert(t, 0, len(s.Owners))
+	assert(t, "218.9Mbps", s.Extra["send"])
+	if s.Info == nil {
+		t.Fatalf("Missing tcp info in %v", s)
+	}
+	assert(t, 204*time.Millisecond, s.Info.Rto)
+	assert(t, 529*time.Microsecond, s.Info.Rtt)
+	assert(t, 1448, s.Info.Mss)
+	assert(t, 10, s.Info.Cwnd)
+	assert(t, 2, s.Info.TotalRetrans)
+	assert(t, uint64(1025), s.Info.BytesAcked)
+
+	s = set[3]
+	assert(t, "*:8080", s.SrcAddr.String())
+	assert(t, "my server", s.Owners[0].Cmd)
+	assert(t, 1000, s.Uid)
+}
+
+const continuationExample = `tcp   ESTAB 0      0      127.0.0.1:59904 127.0.0.1:48271 users:(("curl",pid=1549,fd=18)) uid:1000 ino:24000 sk:11 <->
+	 ts sack cubic wscale:7,7 rto:204 rtt:0.5/0.25 mss:65483 cwnd:16 bytes_acked:19145002
+tcp   LISTEN 0      128    [::]:22          [::]:*
+`
+
+func TestParseOutput_Continuation(t *testing.T) {
+	t.Parallel()
+
+	set, err := ParseOutput(bytes.NewBufferString(continuationExample))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 2 {
+		t.Fatalf("Unexpected set length: wanted 2, found %d: %v", len(set), set)
+	}
+	assert(t, 1549, set[0].Owners[0].Pid)
+	assert(t, 250*time.Microsecond, set[0].Info.RttVar)
+	assert(t, uint64(19145002), set[0].Info.BytesAcked)
+	assert(t, "[::]:22", set[1].SrcAddr.String())
+	assert(t, "", set[1].DstAddr.String())
+	assert(t, -1, set[1].Uid)
+}
+
+func TestParseAddr(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		in  string
+		out string
+	}{
+		{"127.0.0.1:80", "127.0.0.1:80"},
+		{"127.0.0.53%lo:53", "127.0.0.53:53"},
+		{"[fe80::1%eth0]:22", "[fe80::1%eth0]:22"},
+		{"[fe80::1]%eth0:22", "[fe80::1%eth0]:22"},
+		{"[::ffff:10.0.0.1]:443", "[::ffff:10.0.0.1]:443"},
+		{"*:5353", "*:5353"},
+		{"*:*", ""},
+		{"[::]:*", ""},
+	}
+	for i, v := range tt {
+		addr, err := ParseAddr("tcp", v.in)
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		assert(t, v.out, addr.String())
+		assert(t, "tcp", addr.Network())
+	}
+
+	for _, v := range []string{"127.0.0.1", "127.0.0.1:http", "localhost:80"} {
+		if _, err := ParseAddr("tcp", v); err == nil {
+			t.Fatalf("expected error parsing \"%s\"", v)
+		}
+	}
+}
+
+func TestParseUsers(t *testing.T) {
+	t.Parallel()
+
+	owners, err := ParseUsers(`(("nginx",pid=12,fd=6),("a,b",pid=13,fd=7))`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert(t, []Owner{{"nginx", 12, 6}, {"a,b", 13, 7}}, owners)
+
+	for _, v := range []string{`("nginx",pid=12,fd=6)`, `(("nginx",pid=x,fd=6))`, `(("nginx))`} {
+		if _, err := ParseUsers(v); err == nil {
+			t.Fatalf("expected error parsing \"%s\"", v)
+		}
+	}
+}
+
+func assert(t *testing.T, exp, x interface{}) {
+	if !reflect.DeepEqual(exp, x) {
+		t.Fatalf("Assert failed: expected %v, found %v", exp, x)
+	}
+}
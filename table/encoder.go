@@ -0,0 +1,340 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package table encodes open network files as a table, aligned and
+// coloured for terminals.
+package table
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jecoz/lsaddr/onf"
+)
+
+// Options configure the Encoder.
+type Options struct {
+	Width int  // maximum width of the lines, 0 for no limit
+	Color bool // colour states and addresses using ANSI escape codes
+	Group bool // group the open network files by process
+}
+
+// Encoder encodes open network files as a table, one per line.
+type Encoder struct {
+	w    io.Writer
+	opts Options
+}
+
+func NewEncoder(w io.Writer, opts Options) *Encoder {
+	return &Encoder{w: w, opts: opts}
+}
+
+// ANSI escape codes.
+const (
+	reset   = "\x1b[0m"
+	bold    = "\x1b[1m"
+	faint   = "\x1b[2m"
+	green   = "\x1b[32m"
+	yellow  = "\x1b[33m"
+	blue    = "\x1b[34m"
+	magenta = "\x1b[35m"
+	cyan    = "\x1b[36m"
+)
+
+// sep is printed between columns.
+const sep = "  "
+
+// indent is printed before the rows of a group.
+const indent = "  "
+
+type column struct {
+	name  string
+	min   int // width the column may be truncated to, 0 if it may not
+	value func(onf.ONF) string
+	color func(onf.ONF) string
+}
+
+var (
+	pidColumn = column{name: "PID", value: func(f onf.ONF) string {
+		if !f.Attributed() {
+			return "-"
+		}
+		return strconv.Itoa(f.Pid)
+	}}
+	cmdColumn   = column{name: "CMD", min: 10, value: func(f onf.ONF) string { return command(f.Cmd) }}
+	userColumn  = column{name: "USER", min: 6, value: func(f onf.ONF) string { return f.User }}
+	protoColumn = column{name: "PROTO", value: func(f onf.ONF) string { return f.Proto }}
+	stateColumn = column{
+		name: "STATE",
+		value: func(f onf.ONF) string {
+			if f.State == onf.StateUnknown {
+				return ""
+			}
+			return f.State.String()
+		},
+		color: func(f onf.ONF) string { return stateColor(f.State) },
+	}
+	srcColumn = column{
+		name:  "SRC",
+		min:   16,
+		value: func(f onf.ONF) string { return addr(f.Src) },
+		color: func(f onf.ONF) string { return classColor(onf.ClassOfAddr(f.Src)) },
+	}
+	dstColumn = column{
+		name:  "DST",
+		min:   16,
+		value: func(f onf.ONF) string { return addr(f.Dst) },
+		color: func(f onf.ONF) string { return classColor(onf.ClassOfAddr(f.Dst)) },
+	}
+)
+
+// shrinkOrder lists the columns that are truncated first when the table
+// does not fit in the configured width.
+var shrinkOrder = []string{"CMD", "DST", "SRC", "USER"}
+
+// Encode writes "set" into encoder's writer as a table. When grouping is
+// enabled, the open network files are grouped by process, in order of
+// appearance, under a header which replaces the process columns. Some
+// data may have been written to the writer even upon error.
+func (e *Encoder) Encode(set []onf.ONF) error {
+	w := bufio.NewWriter(e.w)
+	if !e.opts.Group {
+		cols := []column{pidColumn, cmdColumn, userColumn, protoColumn, stateColumn, srcColumn, dstColumn}
+		t := e.layout(cols, set, "")
+		t.writeHeader(w)
+		for _, v := range set {
+			t.writeRow(w, v)
+		}
+		return w.Flush()
+	}
+
+	cols := []column{protoColumn, stateColumn, srcColumn, dstColumn}
+	t := e.layout(cols, set, indent)
+	t.writeHeader(w)
+	for _, g := range groupByProcess(set) {
+		e.writeGroupHeader(w, g[0], len(g))
+		for _, v := range g {
+			t.writeRow(w, v)
+		}
+	}
+	return w.Flush()
+}
+
+func (e *Encoder) writeGroupHeader(w *bufio.Writer, f onf.ONF, n int) {
+	title := "unattributed"
+	if f.Attributed() {
+		title = command(f.Cmd) + " (" + strconv.Itoa(f.Pid)
+		if f.User != "" {
+			title += ", " + f.User
+		}
+		title += ")"
+	}
+	title += ": " + strconv.Itoa(n)
+	if n == 1 {
+		title += " socket"
+	} else {
+		title += " sockets"
+	}
+	if e.opts.Width > 0 {
+		title = truncate(title, e.opts.Width)
+	}
+	w.WriteString(e.paint(title, bold))
+	w.WriteByte('\n')
+}
+
+// groupByProcess splits "set" by pid, keeping the order in which the
+// processes appear.
+func groupByProcess(set []onf.ONF) [][]onf.ONF {
+	idx := map[int]int{}
+	groups := [][]onf.ONF{}
+	for _, v := range set {
+		i, ok := idx[v.Pid]
+		if !ok {
+			i = len(groups)
+			idx[v.Pid] = i
+			groups = append(groups, []onf.ONF{})
+		}
+		groups[i] = append(groups[i], v)
+	}
+	return groups
+}
+
+type layout struct {
+	e      *Encoder
+	cols   []column
+	widths []int
+	indent string
+}
+
+// layout sizes each column to its widest value, and then truncates the
+// columns listed in shrinkOrder until the table fits in the configured
+// width, or they cannot be truncated further.
+func (e *Encoder) layout(cols []column, set []onf.ONF, indent string) *layout {
+	widths := make([]int, len(cols))
+	total := len(indent) + len(sep)*(len(cols)-1)
+	for i, c := range cols {
+		widths[i] = width(c.name)
+		for _, v := range set {
+			if n := width(c.value(v)); n > widths[i] {
+				widths[i] = n
+			}
+		}
+		total += widths[i]
+	}
+	for _, name := range shrinkOrder {
+		if e.opts.Width <= 0 || total <= e.opts.Width {
+			break
+		}
+		for i, c := range cols {
+			if c.name != name {
+				continue
+			}
+			min := c.min
+			if n := width(c.name); n > min {
+				min = n
+			}
+			cut := total - e.opts.Width
+			if widths[i]-cut < min {
+				cut = widths[i] - min
+			}
+			if cut > 0 {
+				widths[i] -= cut
+				total -= cut
+			}
+		}
+	}
+	return &layout{e: e, cols: cols, widths: widths, indent: indent}
+}
+
+func (t *layout) writeHeader(w *bufio.Writer) {
+	cells := make([]string, len(t.cols))
+	for i, c := range t.cols {
+		cells[i] = c.name
+	}
+	t.write(w, cells, nil, bold)
+}
+
+func (t *layout) writeRow(w *bufio.Writer, f onf.ONF) {
+	cells := make([]string, len(t.cols))
+	colors := make([]string, len(t.cols))
+	for i, c := range t.cols {
+		cells[i] = c.value(f)
+		if c.color != nil {
+			colors[i] = c.color(f)
+		}
+	}
+	t.write(w, cells, colors, "")
+}
+
+// write pads each cell to the width of its column, but the last one.
+// Colours are applied to the text only, so that they do not affect the
+// alignment.
+func (t *layout) write(w *bufio.Writer, cells, colors []string, color string) {
+	var b strings.Builder
+	b.WriteString(t.indent)
+	for i, v := range cells {
+		v = truncate(v, t.widths[i])
+		pad := t.widths[i] - width(v)
+		c := color
+		if colors != nil {
+			c = colors[i]
+		}
+		b.WriteString(t.e.paint(v, c))
+		if i < len(cells)-1 {
+			b.WriteString(strings.Repeat(" ", pad))
+			b.WriteString(sep)
+		}
+	}
+	// Empty trailing cells would leave the line padded.
+	w.WriteString(strings.TrimRight(b.String(), " "))
+	w.WriteByte('\n')
+}
+
+func (e *Encoder) paint(s, color string) string {
+	if !e.opts.Color || color == "" || s == "" {
+		return s
+	}
+	return color + s + reset
+}
+
+func stateColor(s onf.State) string {
+	switch {
+	case s.IsListening():
+		return green
+	case s.IsConnected():
+		return cyan
+	case s.IsClosing():
+		return yellow
+	default:
+		return ""
+	}
+}
+
+func classColor(c onf.AddrClass) string {
+	switch c {
+	case onf.ClassLoopback, onf.ClassUnspecified:
+		return faint
+	case onf.ClassPrivate, onf.ClassLinkLocal:
+		return blue
+	case onf.ClassMulticast, onf.ClassReserved:
+		return magenta
+	case onf.ClassPublic:
+		return bold
+	default:
+		return ""
+	}
+}
+
+// command returns the name of the command "cmd", which some backends
+// report as a full path.
+func command(cmd string) string {
+	if strings.HasPrefix(cmd, "/") {
+		return path.Base(cmd)
+	}
+	return cmd
+}
+
+func addr(a net.Addr) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
+
+func width(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// truncate shortens "s" to "n" characters replacing its middle with an
+// ellipsis, as the end of command names and addresses, e.g. "(Renderer)"
+// in "Google Chrome Helper (Renderer)" or the port of an address, is
+// often what tells them apart.
+func truncate(s string, n int) string {
+	if width(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	if n <= 1 {
+		return string(r[:n])
+	}
+	head := n / 2
+	tail := n - 1 - head
+	return string(r[:head]) + "…" + string(r[len(r)-tail:])
+}
@@ -0,0 +1,157 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package table
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/onf"
+)
+
+var set = []onf.ONF{
+	{
+		Cmd:   "Google Chrome Helper (Renderer)",
+		Pid:   912,
+		User:  "dani",
+		Proto: "tcp",
+		State: onf.StateEstablished,
+		Src:   internal.NewAddr("tcp", "192.168.0.61:58282"),
+		Dst:   internal.NewAddr("tcp", "162.125.18.133:443"),
+	},
+	{
+		Cmd:   "/usr/sbin/sshd",
+		Pid:   80,
+		User:  "root",
+		Proto: "tcp",
+		State: onf.StateListen,
+		Src:   internal.NewAddr("tcp", "*:22"),
+		Dst:   internal.NewAddr("tcp", ""),
+	},
+	{
+		Cmd:   "Google Chrome Helper (Renderer)",
+		Pid:   912,
+		User:  "dani",
+		Proto: "udp",
+		Src:   internal.NewAddr("udp", "[::1]:60051"),
+		Dst:   internal.NewAddr("udp", "[::1]:60052"),
+	},
+}
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	var w strings.Builder
+	if err := NewEncoder(&w, Options{}).Encode(set); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expOut := `PID  CMD                              USER  PROTO  STATE        SRC                 DST
+912  Google Chrome Helper (Renderer)  dani  tcp    ESTABLISHED  192.168.0.61:58282  162.125.18.133:443
+80   sshd                             root  tcp    LISTEN       *:22
+912  Google Chrome Helper (Renderer)  dani  udp                 [::1]:60051         [::1]:60052
+`
+	if expOut != w.String() {
+		t.Fatalf("Unexpected output: wanted\n\"%s\",\nfound\n\"%s\"", expOut, w.String())
+	}
+}
+
+func TestEncode_Width(t *testing.T) {
+	t.Parallel()
+
+	var w strings.Builder
+	if err := NewEncoder(&w, Options{Width: 80}).Encode(set); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expOut := `PID  CMD         USER  PROTO  STATE        SRC                 DST
+912  Googl…rer)  dani  tcp    ESTABLISHED  192.168.0.61:58282  162.125.….133:443
+80   sshd        root  tcp    LISTEN       *:22
+912  Googl…rer)  dani  udp                 [::1]:60051         [::1]:60052
+`
+	if expOut != w.String() {
+		t.Fatalf("Unexpected output: wanted\n\"%s\",\nfound\n\"%s\"", expOut, w.String())
+	}
+	for _, v := range strings.Split(strings.TrimSpace(w.String()), "\n") {
+		if n := width(v); n > 80 {
+			t.Fatalf("Line exceeds 80 columns: %d: %s", n, v)
+		}
+	}
+}
+
+func TestEncode_Group(t *testing.T) {
+	t.Parallel()
+
+	var w strings.Builder
+	if err := NewEncoder(&w, Options{Group: true}).Encode(set); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expOut := `  PROTO  STATE        SRC                 DST
+Google Chrome Helper (Renderer) (912, dani): 2 sockets
+  tcp    ESTABLISHED  192.168.0.61:58282  162.125.18.133:443
+  udp                 [::1]:60051         [::1]:60052
+sshd (80, root): 1 socket
+  tcp    LISTEN       *:22
+`
+	if expOut != w.String() {
+		t.Fatalf("Unexpected output: wanted\n\"%s\",\nfound\n\"%s\"", expOut, w.String())
+	}
+}
+
+func TestEncode_Color(t *testing.T) {
+	t.Parallel()
+
+	var w strings.Builder
+	if err := NewEncoder(&w, Options{Color: true}).Encode(set[:2]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lines := strings.Split(w.String(), "\n")
+	for _, v := range []string{
+		cyan + "ESTABLISHED" + reset + "  ",
+		blue + "192.168.0.61:58282" + reset,
+		bold + "162.125.18.133:443" + reset,
+	} {
+		if !strings.Contains(lines[1], v) {
+			t.Fatalf("Expected %q in %q", v, lines[1])
+		}
+	}
+	if !strings.Contains(lines[2], green+"LISTEN"+reset) {
+		t.Fatalf("Expected a green state in %q", lines[2])
+	}
+	if !strings.HasSuffix(lines[2], "*:22") {
+		t.Fatalf("Unexpected colour for a wildcard address in %q", lines[2])
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		in  string
+		n   int
+		out string
+	}{
+		{"sshd", 10, "sshd"},
+		{"Google Chrome Helper (Renderer)", 12, "Google…erer)"},
+		{"[2001:db8::1]:443", 11, "[2001…]:443"},
+		{"abc", 1, "a"},
+		{"abc", 2, "a…"},
+	}
+	for _, v := range tt {
+		if out := truncate(v.in, v.n); out != v.out {
+			t.Fatalf("truncate(%q, %d): expected %q, found %q", v.in, v.n, v.out, out)
+		}
+	}
+}
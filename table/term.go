@@ -0,0 +1,43 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package table
+
+import (
+	"os"
+	"strconv"
+)
+
+// DefaultWidth is used for terminals whose width cannot be detected.
+const DefaultWidth = 80
+
+// IsTerminal reports whether "f" is a terminal. Other character devices,
+// such as /dev/null, are not.
+func IsTerminal(f *os.File) bool {
+	return isTerminal(f)
+}
+
+// TerminalWidth returns the number of columns of the terminal "f" is
+// attached to. When it cannot be detected, the COLUMNS environment
+// variable is used, and DefaultWidth after that.
+func TerminalWidth(f *os.File) int {
+	if n := terminalWidth(f); n > 0 {
+		return n
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return DefaultWidth
+}
@@ -0,0 +1,130 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tasklist parses the process list printed by the windows
+// `tasklist` command, which is used to name the processes owning the
+// connections reported by netstat.
+package tasklist
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/jecoz/lsaddr/tool"
+)
+
+// Task is a single entry of the process list.
+type Task struct {
+	Raw        string
+	Image      string // image name, e.g. chrome.exe
+	Pid        int
+	Session    string // session name, e.g. Services or Console
+	SessionNum int
+	MemUsage   int64 // bytes, -1 if unknown
+}
+
+// Run executes ``tasklist /FO CSV /NH'', configured with "opts", and
+// parses its output. Execution errors are of type *tool.Error.
+func Run(ctx context.Context, opts tool.Options) ([]Task, error) {
+	out, err := tool.Run(ctx, opts, "tasklist", "/FO", "CSV", "/NH")
+	if err != nil {
+		return []Task{}, fmt.Errorf("unable to run tasklist: %w", err)
+	}
+	return ParseOutput(bytes.NewBuffer(out))
+}
+
+// ParseOutput expects "r" to contain the output of a
+// ``tasklist /FO CSV /NH'' call. Each record that ``ParseTask'' is able
+// to parse is appended to the final output, the others, e.g. the
+// "INFO: No tasks are running" message, are skipped.
+// Returns an error only if reading from "r" fails.
+func ParseOutput(r io.Reader) ([]Task, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	set := []Task{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return set, nil
+		}
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); ok {
+				log.Printf("skipping tasklist record: %v", err)
+				continue
+			}
+			return set, err
+		}
+		t, err := ParseTask(record)
+		if err != nil {
+			log.Printf("skipping tasklist record %v: %v", record, err)
+			continue
+		}
+		set = append(set, *t)
+	}
+}
+
+// ParseTask parses a single csv record made of image name, pid, session
+// name, session number and memory usage.
+//
+// "record" example, as printed by tasklist:
+// "chrome.exe","4120","Console","1","123,456 K"
+func ParseTask(record []string) (*Task, error) {
+	if len(record) < 5 {
+		return nil, fmt.Errorf("expected at least 5 fields, found %d", len(record))
+	}
+	pid, err := strconv.Atoi(record[1])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing pid: %w", err)
+	}
+	num, err := strconv.Atoi(record[3])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing session number: %w", err)
+	}
+	return &Task{
+		Raw:        `"` + strings.Join(record, `","`) + `"`,
+		Image:      record[0],
+		Pid:        pid,
+		Session:    record[2],
+		SessionNum: num,
+		MemUsage:   ParseMemUsage(record[4]),
+	}, nil
+}
+
+// ParseMemUsage parses the memory usage column, e.g. "123,456 K", into
+// bytes. Thousands separators change with the locale, hence every non
+// digit character is ignored. Returns -1 if "s" contains no digits.
+func ParseMemUsage(s string) int64 {
+	var n int64 = -1
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			continue
+		}
+		if n < 0 {
+			n = 0
+		}
+		n = n*10 + int64(r-'0')
+	}
+	if n < 0 {
+		return n
+	}
+	return n * 1024
+}
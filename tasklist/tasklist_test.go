@@ -0,0 +1,68 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package tasklist
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+const tasklistExample = `
+"System Idle Process","0","Services","0","8 K"
+"System","4","Services","0","1,204 K"
+"svchost.exe","748","Services","0","14,316 K"
+"chrome.exe","4120","Console","1","123,456 K"
+"Code.exe","5012","Console","1","98.304 K"
+INFO: No tasks are running which match the specified criteria.
+"broken.exe","x","Console","1","1 K"
+`
+
+func TestParseOutput(t *testing.T) {
+	t.Parallel()
+
+	set, err := ParseOutput(bytes.NewBufferString(tasklistExample))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 5 {
+		t.Fatalf("Unexpected set length: wanted 5, found %d: %v", len(set), set)
+	}
+	assert(t, Task{
+		Raw:        `"chrome.exe","4120","Console","1","123,456 K"`,
+		Image:      "chrome.exe",
+		Pid:        4120,
+		Session:    "Console",
+		SessionNum: 1,
+		MemUsage:   123456 * 1024,
+	}, set[3])
+	assert(t, "System Idle Process", set[0].Image)
+	assert(t, int64(98304*1024), set[4].MemUsage)
+}
+
+func TestParseMemUsage(t *testing.T) {
+	t.Parallel()
+
+	assert(t, int64(8*1024), ParseMemUsage("8 K"))
+	assert(t, int64(1204*1024), ParseMemUsage("1 204 K"))
+	assert(t, int64(-1), ParseMemUsage("N/A"))
+}
+
+func assert(t *testing.T, exp, x interface{}) {
+	if !reflect.DeepEqual(exp, x) {
+		t.Fatalf("Assert failed: expected %v, found %v", exp, x)
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package template encodes open network files using Go templates, see
+// https://golang.org/pkg/text/template/.
+package template
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jecoz/lsaddr/onf"
+)
+
+// Item is the data the template is executed with, once for each open
+// network file. It provides every field and method of the open network
+// file, with the addresses replaced by their structured form.
+type Item struct {
+	onf.ONF
+	Src Addr
+	Dst Addr
+}
+
+// Addr is an address, split into its components.
+type Addr struct {
+	Network string
+	IP      string // "*" for sockets bound to every interface
+	Zone    string // IPv6 zone, if any
+	Port    int
+	Path    string // unix domain sockets only
+	addr    string
+}
+
+func (a Addr) String() string {
+	return a.addr
+}
+
+// NewItem returns the Item representing "f".
+func NewItem(f onf.ONF) Item {
+	return Item{ONF: f, Src: NewAddr(f.Src), Dst: NewAddr(f.Dst)}
+}
+
+// NewAddr splits "addr" into its components. Missing addresses are
+// returned as the zero Addr.
+func NewAddr(addr net.Addr) Addr {
+	if addr == nil || addr.String() == "" {
+		return Addr{}
+	}
+	a := Addr{Network: addr.Network(), addr: addr.String()}
+	if a.Network == "unix" {
+		a.Path = a.addr
+		return a
+	}
+	host, port, err := net.SplitHostPort(a.addr)
+	if err != nil {
+		return a
+	}
+	a.IP = host
+	if i := strings.Index(host, "%"); i >= 0 {
+		a.IP, a.Zone = host[:i], host[i+1:]
+	}
+	a.Port, _ = strconv.Atoi(port)
+	return a
+}
+
+// Funcs are the functions available to templates, besides the ones
+// predefined by text/template.
+var Funcs = template.FuncMap{
+	"host":   host,
+	"port":   port,
+	"family": family,
+	"age":    age,
+	"join":   join,
+}
+
+func host(a Addr) string {
+	return a.IP
+}
+
+func port(a Addr) int {
+	return a.Port
+}
+
+// family returns the family of "a", e.g. ipv4, ipv6 or unix, which is
+// empty for missing and wildcard addresses.
+func family(a Addr) string {
+	if a.Network == "unix" {
+		return string(onf.FamilyUnix)
+	}
+	ip := net.ParseIP(a.IP)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return string(onf.FamilyIPv4)
+	default:
+		return string(onf.FamilyIPv6)
+	}
+}
+
+// age returns the time elapsed since "t" in a human readable form, using
+// its largest unit only, e.g. "42s", "5m", "3h" or "2d".
+func age(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return strconv.Itoa(int(d/time.Second)) + "s"
+	case d < time.Hour:
+		return strconv.Itoa(int(d/time.Minute)) + "m"
+	case d < 24*time.Hour:
+		return strconv.Itoa(int(d/time.Hour)) + "h"
+	default:
+		return strconv.Itoa(int(d/(24*time.Hour))) + "d"
+	}
+}
+
+// join concatenates "args", or the elements of the slices among them,
+// separating them with "sep".
+func join(sep string, args ...interface{}) string {
+	ss := []string{}
+	for _, v := range args {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			ss = append(ss, fmt.Sprint(v))
+			continue
+		}
+		for i := 0; i < rv.Len(); i++ {
+			ss = append(ss, fmt.Sprint(rv.Index(i).Interface()))
+		}
+	}
+	return strings.Join(ss, sep)
+}
+
+// Encoder executes a template for each open network file, terminating
+// each output with a new line unless the template already does.
+type Encoder struct {
+	w       io.Writer
+	t       *template.Template
+	newline bool
+}
+
+// NewEncoder parses "text" as a template. Unknown fields are reported
+// here, by executing the template against an empty Item, rather than
+// when the first open network file is encoded.
+func NewEncoder(w io.Writer, text string) (*Encoder, error) {
+	t, err := template.New("lsaddr").Funcs(Funcs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Execute(ioutil.Discard, Item{}); err != nil && unknownField.MatchString(err.Error()) {
+		return nil, explain(err)
+	}
+	return &Encoder{w: w, t: t, newline: !strings.HasSuffix(text, "\n")}, nil
+}
+
+// Encode writes the output of the template for each item of "set" into
+// encoder's writer. Some data may have been written to the writer even
+// upon error.
+func (e *Encoder) Encode(set []onf.ONF) error {
+	w := bufio.NewWriter(e.w)
+	for _, v := range set {
+		if err := e.t.Execute(w, NewItem(v)); err != nil {
+			w.Flush()
+			return fmt.Errorf("unable to execute template on %v: %w", v, explain(err))
+		}
+		if e.newline {
+			w.WriteByte('\n')
+		}
+	}
+	return w.Flush()
+}
+
+var unknownField = regexp.MustCompile(`^(.*): can't evaluate field (\w+) in type (\S+)$`)
+
+// explain replaces the errors text/template returns for unknown fields
+// with one listing the available ones.
+func explain(err error) error {
+	m := unknownField.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	var fields []string
+	switch m[3] {
+	case reflect.TypeOf(Item{}).String():
+		fields = fieldNames(reflect.TypeOf(Item{}))
+	case reflect.TypeOf(Addr{}).String():
+		fields = fieldNames(reflect.TypeOf(Addr{}))
+	default:
+		return fmt.Errorf("%s: field %s does not exist, as the value is of type %s", m[1], m[2], m[3])
+	}
+	return fmt.Errorf("%s: unknown field %s, available fields are %s", m[1], m[2], strings.Join(fields, ", "))
+}
+
+// fieldNames returns the exported fields of "t", including the ones of
+// its embedded structs, in order of declaration.
+func fieldNames(t reflect.Type) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	var walk func(reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			switch {
+			case f.Anonymous:
+				walk(f.Type)
+			case f.PkgPath == "" && !seen[f.Name]:
+				seen[f.Name] = true
+				names = append(names, f.Name)
+			}
+		}
+	}
+	walk(t)
+	return names
+}
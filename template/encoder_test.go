@@ -0,0 +1,119 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package template
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jecoz/lsaddr/internal"
+	"github.com/jecoz/lsaddr/onf"
+)
+
+var set = []onf.ONF{
+	{
+		Cmd:       "foo",
+		Pid:       101,
+		Family:    onf.FamilyIPv6,
+		Proto:     "tcp",
+		State:     onf.StateEstablished,
+		Src:       internal.NewAddr("tcp", "[fe80::1%eth0]:54104"),
+		Dst:       internal.NewAddr("tcp", "52.94.218.7:443"),
+		CreatedAt: time.Now().Add(-5*time.Minute - time.Second),
+	},
+	{
+		Pid:    102,
+		Family: onf.FamilyUnix,
+		Proto:  "unix",
+		Src:    onf.NewUnixAddr("/run/foo.sock"),
+		Dst:    onf.NewUnixAddr(""),
+	},
+}
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		text string
+		out  string
+	}{
+		{"{{.Pid}} {{.Cmd}} {{.Dst.IP}}", "101 foo 52.94.218.7\n102  \n"},
+		{"{{.Src}} {{.Src.Zone}} {{.Src.Port}} {{.Src.Path}}\n", "[fe80::1%eth0]:54104 eth0 54104 \n/run/foo.sock  0 /run/foo.sock\n"},
+		{"{{host .Src}}|{{port .Dst}}|{{family .Src}}|{{family .Dst}}", "fe80::1|443|ipv6|ipv4\n|0|unix|\n"},
+		{`{{join "," .Pid .State .Proto}}`, "101,ESTABLISHED,tcp\n102,UNKNOWN,unix\n"},
+		{"{{if .Attributed}}{{.State}}{{end}} {{.IsUnix}}", "ESTABLISHED false\nUNKNOWN true\n"},
+		{"{{age .CreatedAt}}", "5m\n\n"},
+	}
+	for _, v := range tt {
+		var w strings.Builder
+		enc, err := NewEncoder(&w, v.text)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", v.text, err)
+		}
+		if err := enc.Encode(set); err != nil {
+			t.Fatalf("%s: unexpected error: %v", v.text, err)
+		}
+		if v.out != w.String() {
+			t.Fatalf("%s: unexpected output: wanted %q, found %q", v.text, v.out, w.String())
+		}
+	}
+}
+
+func TestNewEncoder_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		text string
+		msg  string
+	}{
+		{"{{.Foo}}", "unknown field Foo, available fields are Raw, Cmd, Pid, Fd, User, Uid, Family, Proto, State, Inode, Src, Dst, PeerPid, CreatedAt"},
+		{"{{.Dst.Host}}", "unknown field Host, available fields are Network, IP, Zone, Port, Path"},
+		{"{{.Cmd.Name}}", "field Name does not exist, as the value is of type string"},
+		{"{{.Pid", "unclosed action"},
+		{"{{humanize .CreatedAt}}", `function "humanize" not defined`},
+	}
+	for _, v := range tt {
+		_, err := NewEncoder(&strings.Builder{}, v.text)
+		if err == nil {
+			t.Fatalf("%s: expected error", v.text)
+		}
+		if !strings.Contains(err.Error(), v.msg) {
+			t.Fatalf("%s: expected %q in %q", v.text, v.msg, err)
+		}
+	}
+}
+
+func TestAge(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		d   time.Duration
+		out string
+	}{
+		{42 * time.Second, "42s"},
+		{3*time.Hour + 59*time.Minute, "3h"},
+		{50 * time.Hour, "2d"},
+	}
+	for _, v := range tt {
+		if out := age(time.Now().Add(-v.d)); out != v.out {
+			t.Fatalf("%v: expected %s, found %s", v.d, v.out, out)
+		}
+	}
+	if out := age(time.Time{}); out != "" {
+		t.Fatalf("Unexpected age of the zero time: %s", out)
+	}
+}
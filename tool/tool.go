@@ -0,0 +1,126 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tool executes the external programs used to collect open
+// network files, such as lsof and netstat.
+package tool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the execution timeout suggested to callers. lsof
+// may take a few seconds on machines with many open files.
+const DefaultTimeout = time.Second * 10
+
+// Options configures how an external tool is executed. The zero value
+// runs the tool as found in $PATH, without timeout.
+type Options struct {
+	Timeout time.Duration // zero means no timeout
+	Tool    string        // tool Path and Args apply to, every tool when empty
+	Path    string        // overrides the binary to execute
+	Args    []string      // appended to the default arguments
+	Elevate []string      // prefix used to gain privileges, e.g. ["sudo", "-n"]
+}
+
+// Error is returned when an external tool could not be executed or
+// exited with an error.
+type Error struct {
+	Cmd      string // command line executed
+	ExitCode int    // -1 if the process did not exit on its own
+	Stderr   string // captured standard error
+	Err      error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s: %v", e.Cmd, e.Err)
+	if e.ExitCode >= 0 {
+		msg = fmt.Sprintf("%s: exit code %d", e.Cmd, e.ExitCode)
+	}
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Command returns the name and arguments of the command that Run would
+// execute. The Path and Args of "opts" are ignored when they are meant
+// for a tool other than "name", as backends falling back to each other
+// share the same options.
+func Command(opts Options, name string, args ...string) (string, []string) {
+	args = append([]string{}, args...)
+	if opts.Tool == "" || opts.Tool == name {
+		if opts.Path != "" {
+			name = opts.Path
+		}
+		args = append(args, opts.Args...)
+	}
+	if len(opts.Elevate) > 0 {
+		args = append(append(append([]string{}, opts.Elevate[1:]...), name), args...)
+		name = opts.Elevate[0]
+	}
+	return name, args
+}
+
+// Run executes the tool "name" with "args", configured with "opts",
+// and returns its standard output. The process is killed when "ctx" is
+// done or the timeout expires. Errors are of type *Error.
+func Run(ctx context.Context, opts Options, name string, args ...string) ([]byte, error) {
+	name, args = Command(opts, name, args...)
+	line := strings.Join(append([]string{name}, args...), " ")
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	log.Printf("Executing: %s", line)
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err == nil {
+		return stdout.Bytes(), nil
+	}
+
+	e := &Error{
+		Cmd:      line,
+		ExitCode: -1,
+		Stderr:   stderr.String(),
+		Err:      err,
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		// The process was killed by us.
+		e.Err = ctxErr
+		return stdout.Bytes(), e
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		e.ExitCode = exitErr.ExitCode()
+	}
+	return stdout.Bytes(), e
+}
@@ -0,0 +1,87 @@
+// Copyright © 2019 Jecoz
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package tool_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/jecoz/lsaddr/tool"
+)
+
+func TestCommand(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		opts tool.Options
+		name string
+		args []string
+	}{
+		{tool.Options{}, "lsof", []string{"-i", "-n", "-P"}},
+		{tool.Options{Path: "/usr/sbin/lsof"}, "/usr/sbin/lsof", []string{"-i", "-n", "-P"}},
+		{tool.Options{Args: []string{"-a", "-u", "root"}}, "lsof", []string{"-i", "-n", "-P", "-a", "-u", "root"}},
+		{tool.Options{Elevate: []string{"sudo", "-n"}}, "sudo", []string{"-n", "lsof", "-i", "-n", "-P"}},
+		{tool.Options{Tool: "lsof", Path: "/usr/sbin/lsof", Args: []string{"-a"}}, "/usr/sbin/lsof", []string{"-i", "-n", "-P", "-a"}},
+		{tool.Options{Tool: "ss", Path: "/opt/ss", Args: []string{"-4"}}, "lsof", []string{"-i", "-n", "-P"}},
+	}
+	for i, v := range tt {
+		name, args := tool.Command(v.opts, "lsof", "-i", "-n", "-P")
+		if name != v.name || !reflect.DeepEqual(args, v.args) {
+			t.Fatalf("%d: unexpected command: %s %v", i, name, args)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+
+	out, err := tool.Run(context.Background(), tool.Options{}, "sh", "-c", "echo foo")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(out) != "foo\n" {
+		t.Fatalf("Unexpected output: %q", out)
+	}
+
+	_, err = tool.Run(context.Background(), tool.Options{}, "sh", "-c", "echo permission denied >&2; exit 3")
+	var e *tool.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if e.ExitCode != 3 || e.Stderr != "permission denied\n" {
+		t.Fatalf("Unexpected error content: %+v", e)
+	}
+
+	_, err = tool.Run(context.Background(), tool.Options{Timeout: time.Millisecond * 50}, "sleep", "5")
+	if !errors.As(err, &e) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if e.ExitCode != -1 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Unexpected error content: %+v", e)
+	}
+
+	_, err = tool.Run(context.Background(), tool.Options{Path: "/nonexistent/lsof"}, "lsof")
+	if !errors.As(err, &e) || e.ExitCode != -1 {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}